@@ -1,24 +1,57 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"os"
+	"os/signal"
 	"path"
+	"project-integrity-calculator/internal/cli"
+	"project-integrity-calculator/internal/forge"
+	"project-integrity-calculator/internal/gh"
 	"project-integrity-calculator/internal/io"
 	"project-integrity-calculator/internal/logging"
 	"project-integrity-calculator/internal/processor"
+	"project-integrity-calculator/internal/score"
+	"project-integrity-calculator/internal/vcs"
 	"strings"
+	"syscall"
 	"time"
 )
 
 var (
-	token              = flag.String("token", "", "GitHub access token")
+	token              = flag.String("token", "", "Access token of the forge, e.g., a GitHub, GitLab, or Gitea access token. For Gerrit, username:password with the HTTP password of the user.")
+	appID              = flag.String("appId", "", "ID of the GitHub App to authenticate as. Replaces the token.")
+	appKey             = flag.String("appKey", "", "Path to the PEM encoded private key of the GitHub App.")
+	installationID     = flag.Int64("installationId", 0, "Installation ID of the GitHub App. Defaults to the installation of the app for the analyzed repository.")
 	cloneTarget        = flag.String("cloneTarget", "", "Target to clone. Defaults to tmp")
 	logLevel           = flag.Int("logLevel", 0, "Can be 0 for INFO, -4 for DEBUG, 4 for WARN, or 8 for ERROR. Defaults to INFO.")
 	out                = flag.String("out", "", "Directory to which the output is written. Defaults to the current working directory.")
 	in                 = flag.String("in", "", "Input file with the repositories to process.")
 	ignoreFirstCommits = flag.Bool("ignore", false, "If set to true all commits until the first PR has been merged are ignored. Defaults to false.")
 	filterResults      = flag.Bool("filter", false, "If set to true all repositories with more than 50% of commits going against the rules are filtered. Defaults to false.")
+	weightPR           = flag.Float64("weightPR", score.DefaultWeights().CommitsWithPR, "Weight of the share of commits with PR in the Code Integrity Score.")
+	weightReviewed     = flag.Float64("weightReviewed", score.DefaultWeights().ReviewedCommits, "Weight of the share of commits with a reviewed PR in the Code Integrity Score.")
+	weightSigned       = flag.Float64("weightSigned", score.DefaultWeights().SignedCommits, "Weight of the share of signed commits in the Code Integrity Score.")
+	weightForcePush    = flag.Float64("weightForcePush", score.DefaultWeights().ForcePushes, "Weight of the number of force pushes in the Code Integrity Score.")
+	forgeKind          = flag.String("forge", string(forge.GitHub), "Forge hosting the repositories. Can be github, gitlab, gitea (also for Forgejo), or gerrit. Defaults to github.")
+	forgeURL           = flag.String("forgeUrl", "", "Base URL of the forge, e.g., https://gitlab.example.com. Required for Gitea and Gerrit, defaults to https://gitlab.com for GitLab.")
+	allPatchSets       = flag.Bool("allPatchSets", false, "If set to true the commits of all patch sets of a Gerrit change are considered reviewed, not only the final one. Defaults to false.")
+	keepClone          = flag.Bool("keepClone", false, "If set to true the clone is kept after the analysis and updated in later runs instead of cloning again. Defaults to false.")
+	incremental        = flag.Bool("incremental", false, "If set to true the results of the previous run in out are continued. Only new commits and PRs are analyzed. Defaults to false.")
+	patchIdCache       = flag.String("patchIdCache", "", "File to persist patch ids in, which can be shared between runs and repositories. Patch ids are only cached in memory if empty.")
+	graphQLURL         = flag.String("graphqlUrl", gh.DefaultGraphQLURL, "GraphQL endpoint of the GitHub API. Use https://<host>/api/graphql for GitHub Enterprise Server.")
+	restURL            = flag.String("restUrl", gh.DefaultRestURL, "REST endpoint of the GitHub API. Use https://<host>/api/v3 for GitHub Enterprise Server.")
+	cacheDir           = flag.String("cache", "", "Directory to cache GitHub API responses in. Responses are not cached if empty.")
+	cacheMode          = flag.String("cacheMode", string(gh.CacheRefresh), "Can be record to always query the API, replay to only use cached responses, or refresh to query the API for responses older than cacheMaxAge. Defaults to refresh.")
+	cacheMaxAge        = flag.Duration("cacheMaxAge", 24*time.Hour, "Max age of cached responses in refresh mode. Defaults to 24h.")
+	timeout            = flag.Duration("timeout", 0, "Maximum duration of the whole run, e.g., 24h. Remaining repositories are skipped. Not limited if zero.")
+	repoTimeout        = flag.Duration("repoTimeout", 0, "Maximum duration of the analysis of a single repository, e.g., 2h. Repositories exceeding it fail. Not limited if zero.")
+	gpgKeyring         = flag.String("gpgKeyring", "", "OpenPGP keyring with the trusted signing keys. Signatures are verified independently of the GnuPG setup of the host.")
+	allowedSigners     = flag.String("allowedSigners", "", "SSH allowed signers file with the trusted signing keys.")
+	fetchSigningKeys   = flag.Bool("fetchSigningKeys", false, "If set to true the public GPG and SSH signing keys of the contributors are fetched from GitHub. Their signatures are valid, but not trusted. Defaults to false.")
+	forgeSignatures    = flag.Bool("forgeSignatures", false, "If set to true the verification of the commit signatures by GitHub is fetched and reconciled with the local verification. Defaults to false.")
+	signaturePolicy    = cli.SignaturePolicyFlags()
 )
 
 func main() {
@@ -33,8 +66,18 @@ func main() {
 		panic("in is required")
 	}
 
-	if *token == "" {
-		panic("token is required")
+	mode, err := gh.ParseCacheMode(*cacheMode)
+	if err != nil {
+		panic(err)
+	}
+
+	if *appID != "" && *appKey == "" {
+		panic("appKey is required if appId is set")
+	}
+
+	// replaying cached responses doesn't require access to the API
+	if *token == "" && *appID == "" && (*cacheDir == "" || mode != gh.CacheReplay) {
+		panic("token or appId is required")
 	}
 
 	if *cloneTarget == "" {
@@ -54,31 +97,125 @@ func main() {
 		panic(err)
 	}
 
+	// the analysis is stopped on interrupts, so temporary clones are removed
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	// a second interrupt terminates the program immediately
+	context.AfterFunc(ctx, stop)
+	if *timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *timeout)
+		defer cancel()
+	}
+
+	// the cache is shared between all repositories
+	var patchIds *vcs.PatchIdCache
+	if *patchIdCache != "" {
+		patchIds, err = vcs.OpenPatchIdCache(*patchIdCache, 10_000_000)
+		if err != nil {
+			panic(err)
+		}
+	} else {
+		patchIds = vcs.NewPatchIdCache(10_000_000)
+	}
+	defer func() {
+		if err := patchIds.Close(); err != nil {
+			logger.Warn("Failed to close patch id cache", "err", err)
+		}
+	}()
+
+	// the clients are shared between all repositories, so they share the rate limit
+	// and the token. The installation of a GitHub App depends on the owner, unless set.
+	clients := map[string]*gh.Client{}
+
 	failedRepos := 0
-	for _, r := range input.Data.Search.Nodes {
+	skippedRepos := 0
+	for i, r := range input.Data.Search.Nodes {
+		if ctx.Err() != nil {
+			skippedRepos = len(input.Data.Search.Nodes) - i
+			logger.Warn("Run stopped. Skipping remaining repositories.", "err", ctx.Err(), "number of skipped repos", skippedRepos)
+			break
+		}
+
+		owner, repoName, err := processor.SplitOwnerAndRepo(forge.Kind(*forgeKind), r.NameWithOwner)
+		if err != nil {
+			failedRepos++
+			logger.Warn("Invalid repository name", "err", err)
+			continue
+		}
+
+		// the owner is part of the path, so kept clones of repositories with the same name don't collide
+		clonePath := path.Join(*cloneTarget, owner, repoName+".git")
+		fileName := strings.ReplaceAll(owner, "/", "_") + repoName + "-result.json"
 
-		ownerAndRepoSplit := strings.Split(r.NameWithOwner, "/")
+		var previous *io.Repo
+		if *incremental {
+			previous, err = io.GetResult(path.Join(*out, fileName))
+			if err != nil {
+				logger.Warn("No previous result found. Analyzing the whole branch.", "repo", r.NameWithOwner, "err", err)
+			}
+		}
 
-		clonePath := path.Join(*cloneTarget, ownerAndRepoSplit[1])
 		config := processor.RepoConfig{
-			Owner:              ownerAndRepoSplit[0],
-			Repo:               ownerAndRepoSplit[1],
+			Owner:              owner,
+			Repo:               repoName,
 			ClonePath:          clonePath,
 			Branch:             "",
 			Token:              *token,
 			Out:                *out,
 			IgnoreFirstCommits: *ignoreFirstCommits,
 			FilterResults:      *filterResults,
+			Weights: &score.Weights{
+				CommitsWithPR:   *weightPR,
+				ReviewedCommits: *weightReviewed,
+				SignedCommits:   *weightSigned,
+				ForcePushes:     *weightForcePush,
+			},
+			Forge:            forge.Kind(*forgeKind),
+			ForgeURL:         *forgeURL,
+			AllPatchSets:     *allPatchSets,
+			KeepClone:        *keepClone,
+			Previous:         previous,
+			PatchIds:         patchIds,
+			AppID:            *appID,
+			AppKeyPath:       *appKey,
+			InstallationID:   *installationID,
+			GraphQLURL:       *graphQLURL,
+			RestURL:          *restURL,
+			CacheDir:         *cacheDir,
+			CacheMode:        mode,
+			CacheMaxAge:      *cacheMaxAge,
+			Timeout:          *repoTimeout,
+			GPGKeyring:       *gpgKeyring,
+			AllowedSigners:   *allowedSigners,
+			FetchSigningKeys: *fetchSigningKeys,
+			ForgeSignatures:  *forgeSignatures,
+			SignaturePolicy:  signaturePolicy(),
+		}
+
+		clientKey := ""
+		if *appID != "" && *installationID == 0 {
+			clientKey = owner
+		}
+		client, ok := clients[clientKey]
+		if !ok {
+			client, err = processor.NewClient(config)
+			if err != nil {
+				failedRepos++
+				logger.Warn("Create client failed", "err", err)
+				continue
+			}
+			clients[clientKey] = client
 		}
+		config.Client = client
 
-		repo, err := processor.ProcessRepo(config)
+		repo, err := processor.ProcessRepo(ctx, config)
 		if err != nil {
 			failedRepos++
 			logger.Warn("Process repo failed", "err", err)
 			continue
 		}
 
-		fileName := config.Owner + config.Repo + "-result.json"
 		err = io.StoreResult(*out, fileName, *repo)
 		if err != nil {
 			failedRepos++
@@ -87,5 +224,5 @@ func main() {
 		}
 	}
 	elapsed := time.Since(start)
-	logger.Info("Execution finished", "time elapsed", elapsed, "number of failed repos", failedRepos)
+	logger.Info("Execution finished", "time elapsed", elapsed, "number of failed repos", failedRepos, "number of skipped repos", skippedRepos)
 }
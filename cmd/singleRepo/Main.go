@@ -1,24 +1,59 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"os"
+	"os/signal"
 	"path"
+	"path/filepath"
+	"project-integrity-calculator/internal/cli"
+	"project-integrity-calculator/internal/forge"
+	"project-integrity-calculator/internal/gh"
 	"project-integrity-calculator/internal/io"
 	"project-integrity-calculator/internal/logging"
 	"project-integrity-calculator/internal/processor"
+	"project-integrity-calculator/internal/score"
+	"project-integrity-calculator/internal/vcs"
 	"strings"
+	"syscall"
 	"time"
 )
 
 var (
 	ownerAndRepo       = flag.String("ownerAndRepo", "", "GitHub repository link (e.g., https://github.com/owner/repo)")
-	token              = flag.String("token", "", "GitHub access token")
+	token              = flag.String("token", "", "Access token of the forge, e.g., a GitHub, GitLab, or Gitea access token. For Gerrit, username:password with the HTTP password of the user.")
+	appID              = flag.String("appId", "", "ID of the GitHub App to authenticate as. Replaces the token.")
+	appKey             = flag.String("appKey", "", "Path to the PEM encoded private key of the GitHub App.")
+	installationID     = flag.Int64("installationId", 0, "Installation ID of the GitHub App. Defaults to the installation of the app for the analyzed repository.")
 	targetBranch       = flag.String("branch", "", "Target branch to analyze. Defaults to the default branch of the repository")
 	cloneTarget        = flag.String("cloneTarget", "", "Target to clone. Defaults to tmp")
 	logLevel           = flag.Int("logLevel", 0, "Can be 0 for INFO, -4 for DEBUG, 4 for WARN, or 8 for ERROR. Defaults to INFO.")
 	out                = flag.String("out", "", "Directory to which the output is written. Defaults to the current working directory.")
 	ignoreFirstCommits = flag.Bool("ignore", false, "If set to true all commits until the first PR has been merged are ignored. Defaults to false.")
+	weightPR           = flag.Float64("weightPR", score.DefaultWeights().CommitsWithPR, "Weight of the share of commits with PR in the Code Integrity Score.")
+	weightReviewed     = flag.Float64("weightReviewed", score.DefaultWeights().ReviewedCommits, "Weight of the share of commits with a reviewed PR in the Code Integrity Score.")
+	weightSigned       = flag.Float64("weightSigned", score.DefaultWeights().SignedCommits, "Weight of the share of signed commits in the Code Integrity Score.")
+	weightForcePush    = flag.Float64("weightForcePush", score.DefaultWeights().ForcePushes, "Weight of the number of force pushes in the Code Integrity Score.")
+	forgeKind          = flag.String("forge", string(forge.GitHub), "Forge hosting the repositories. Can be github, gitlab, gitea (also for Forgejo), gerrit, or local. Defaults to github.")
+	forgeURL           = flag.String("forgeUrl", "", "Base URL of the forge, e.g., https://gitlab.example.com. Required for Gitea and Gerrit, defaults to https://gitlab.com for GitLab.")
+	allPatchSets       = flag.Bool("allPatchSets", false, "If set to true the commits of all patch sets of a Gerrit change are considered reviewed, not only the final one. Defaults to false.")
+	repoPath           = flag.String("repoPath", "", "Path of an existing bare clone or mirror to analyze instead of cloning the repository. Its branches are overwritten from origin, it is never deleted. Required for the local forge.")
+	manifest           = flag.String("manifest", "", "JSON or JSON Lines file with the merged PRs of the repository in the GitHub format. Required for the local forge.")
+	keepClone          = flag.Bool("keepClone", false, "If set to true the clone is kept after the analysis and updated in later runs instead of cloning again. Defaults to false.")
+	incremental        = flag.Bool("incremental", false, "If set to true the result of the previous run in out is continued. Only new commits and PRs are analyzed. Defaults to false.")
+	patchIdCache       = flag.String("patchIdCache", "", "File to persist patch ids in, which can be shared between runs and repositories. Patch ids are only cached in memory if empty.")
+	graphQLURL         = flag.String("graphqlUrl", gh.DefaultGraphQLURL, "GraphQL endpoint of the GitHub API. Use https://<host>/api/graphql for GitHub Enterprise Server.")
+	restURL            = flag.String("restUrl", gh.DefaultRestURL, "REST endpoint of the GitHub API. Use https://<host>/api/v3 for GitHub Enterprise Server.")
+	cacheDir           = flag.String("cache", "", "Directory to cache GitHub API responses in. Responses are not cached if empty.")
+	cacheMode          = flag.String("cacheMode", string(gh.CacheRefresh), "Can be record to always query the API, replay to only use cached responses, or refresh to query the API for responses older than cacheMaxAge. Defaults to refresh.")
+	cacheMaxAge        = flag.Duration("cacheMaxAge", 24*time.Hour, "Max age of cached responses in refresh mode. Defaults to 24h.")
+	timeout            = flag.Duration("timeout", 0, "Maximum duration of the analysis, e.g., 2h. Not limited if zero.")
+	gpgKeyring         = flag.String("gpgKeyring", "", "OpenPGP keyring with the trusted signing keys. Signatures are verified independently of the GnuPG setup of the host.")
+	allowedSigners     = flag.String("allowedSigners", "", "SSH allowed signers file with the trusted signing keys.")
+	fetchSigningKeys   = flag.Bool("fetchSigningKeys", false, "If set to true the public GPG and SSH signing keys of the contributors are fetched from GitHub. Their signatures are valid, but not trusted. Defaults to false.")
+	forgeSignatures    = flag.Bool("forgeSignatures", false, "If set to true the verification of the commit signatures by GitHub is fetched and reconciled with the local verification. Defaults to false.")
+	signaturePolicy    = cli.SignaturePolicyFlags()
 )
 
 func main() {
@@ -29,19 +64,36 @@ func main() {
 
 	logger := logging.SetUpLogging(*logLevel)
 
+	local := forge.Kind(*forgeKind) == forge.Local
+	if local && *ownerAndRepo == "" {
+		*ownerAndRepo = filepath.Base(*repoPath)
+	}
+
 	if *ownerAndRepo == "" {
 		panic("ownerAndRepo is required")
 	}
 
-	ownerAndRepoSplit := strings.Split(*ownerAndRepo, "/")
-	// TODO: do validation for format
+	owner, repoName, err := processor.SplitOwnerAndRepo(forge.Kind(*forgeKind), *ownerAndRepo)
+	if err != nil {
+		panic(err)
+	}
+
+	mode, err := gh.ParseCacheMode(*cacheMode)
+	if err != nil {
+		panic(err)
+	}
+
+	if *appID != "" && *appKey == "" {
+		panic("appKey is required if appId is set")
+	}
 
-	if *token == "" {
-		panic("token is required")
+	// local repositories and replaying cached responses don't require access to the API
+	if !local && *token == "" && *appID == "" && (*cacheDir == "" || mode != gh.CacheReplay) {
+		panic("token or appId is required")
 	}
 
 	if *cloneTarget == "" {
-		*cloneTarget = path.Join(os.TempDir(), "codeintegrity", ownerAndRepoSplit[1])
+		*cloneTarget = path.Join(os.TempDir(), "codeintegrity", repoName)
 	}
 
 	if *out == "" {
@@ -52,22 +104,82 @@ func main() {
 		*out = wd
 	}
 
+	// the analysis is stopped on interrupts, so temporary clones are removed
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	// a second interrupt terminates the program immediately
+	context.AfterFunc(ctx, stop)
+	if *timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *timeout)
+		defer cancel()
+	}
+
+	var patchIds *vcs.PatchIdCache
+	if *patchIdCache != "" {
+		patchIds, err = vcs.OpenPatchIdCache(*patchIdCache, 10_000_000)
+		if err != nil {
+			panic(err)
+		}
+		defer func() {
+			if err := patchIds.Close(); err != nil {
+				logger.Warn("Failed to close patch id cache", "err", err)
+			}
+		}()
+	}
+
+	fileName := strings.ReplaceAll(owner, "/", "_") + repoName + "result.json"
+
+	var previous *io.Repo
+	if *incremental {
+		previous, err = io.GetResult(path.Join(*out, fileName))
+		if err != nil {
+			logger.Warn("No previous result found. Analyzing the whole branch.", "err", err)
+		}
+	}
+
 	config := processor.RepoConfig{
-		Owner:              ownerAndRepoSplit[0],
-		Repo:               ownerAndRepoSplit[1],
+		Owner:              owner,
+		Repo:               repoName,
 		ClonePath:          *cloneTarget,
 		Branch:             *targetBranch,
 		Token:              *token,
 		Out:                *out,
 		IgnoreFirstCommits: *ignoreFirstCommits,
+		Weights: &score.Weights{
+			CommitsWithPR:   *weightPR,
+			ReviewedCommits: *weightReviewed,
+			SignedCommits:   *weightSigned,
+			ForcePushes:     *weightForcePush,
+		},
+		Forge:            forge.Kind(*forgeKind),
+		ForgeURL:         *forgeURL,
+		AllPatchSets:     *allPatchSets,
+		KeepClone:        *keepClone,
+		RepoPath:         *repoPath,
+		Manifest:         *manifest,
+		Previous:         previous,
+		PatchIds:         patchIds,
+		AppID:            *appID,
+		AppKeyPath:       *appKey,
+		InstallationID:   *installationID,
+		GraphQLURL:       *graphQLURL,
+		RestURL:          *restURL,
+		CacheDir:         *cacheDir,
+		CacheMode:        mode,
+		CacheMaxAge:      *cacheMaxAge,
+		GPGKeyring:       *gpgKeyring,
+		AllowedSigners:   *allowedSigners,
+		FetchSigningKeys: *fetchSigningKeys,
+		ForgeSignatures:  *forgeSignatures,
+		SignaturePolicy:  signaturePolicy(),
 	}
 
-	repo, err := processor.ProcessRepo(config)
+	repo, err := processor.ProcessRepo(ctx, config)
 	if err != nil {
 		panic(err)
 	}
 
-	fileName := ownerAndRepoSplit[0] + ownerAndRepoSplit[1] + "result.json"
 	err = io.StoreResult(*out, fileName, *repo)
 	if err != nil {
 		panic(err)
@@ -9,7 +9,6 @@ import (
 	"path/filepath"
 	"project-integrity-calculator/internal/io"
 	"strings"
-	"time"
 )
 
 var in = flag.String("in", "", "Path to the result to be transformed")
@@ -45,9 +44,6 @@ func writeCommitsToCSVFile(folderPath, outputFilePath string) error {
 		return fmt.Errorf("failed to write CSV header: %w", err)
 	}
 
-	// Define the date layout string matching the provided examples
-	const layout = "2006-01-02 15:04:05 -0700"
-
 	// 3. Read the contents of the input folder
 	entries, err := os.ReadDir(folderPath)
 	if err != nil {
@@ -81,11 +77,11 @@ func writeCommitsToCSVFile(folderPath, outputFilePath string) error {
 
 		// 6. Process commits in the parsed Repo and write to CSV
 		for _, commit := range repo.CommitsWithoutPR {
-			// Parse the commit date string
-			t, err := time.Parse(layout, commit.Date)
-			if err != nil {
+			// The commit date is in the time zone of the committer
+			t := commit.Date
+			if t.IsZero() {
 				// Log the error and skip this commit
-				log.Printf("Skipping commit %s (Date: %s) in file %s due to date parsing error: %v", commit.GitOID, commit.Date, filePath, err)
+				log.Printf("Skipping commit %s in file %s due to missing date", commit.GitOID, filePath)
 				continue
 			}
 
@@ -21,7 +21,10 @@ func TestIntegration(t *testing.T) {
 	// run multirepo with TestRepos.json
 	// write result data into tmp
 	token := os.Getenv("GH_TOKEN")
-	if token == "" {
+	// optionally replay or record the GitHub API responses
+	cacheDir := os.Getenv("GH_CACHE_DIR")
+	cacheMode := os.Getenv("GH_CACHE_MODE")
+	if token == "" && (cacheDir == "" || cacheMode != "replay") {
 		t.Fatalf("Failed to get token from environment. GH_TOKEN must be set")
 	}
 	wd, err := os.Getwd()
@@ -34,7 +37,14 @@ func TestIntegration(t *testing.T) {
 	app := path.Join(dir, "cmd", "multiRepo", "Main.go")
 	in := path.Join(dir, "integrationTests", "TestRepos.json")
 
-	cmd := exec.Command("go", "run", app, "--in", in, "--out", out, "--token", token)
+	args := []string{"run", app, "--in", in, "--out", out, "--token", token}
+	if cacheDir != "" {
+		args = append(args, "--cache", cacheDir)
+		if cacheMode != "" {
+			args = append(args, "--cacheMode", cacheMode)
+		}
+	}
+	cmd := exec.Command("go", args...)
 	cmd.Dir = dir
 	o, err := cmd.CombinedOutput()
 	if err != nil {
@@ -0,0 +1,34 @@
+// Package cli contains the command line handling shared by the commands.
+package cli
+
+import (
+	"flag"
+	"strings"
+
+	"project-integrity-calculator/internal/io"
+)
+
+// SignaturePolicyFlags registers the flags configuring the signature policy on the
+// default flag set. The returned function returns the policy once the flags are parsed.
+func SignaturePolicyFlags() func() *io.SignaturePolicy {
+	since := flag.String("signaturesRequiredSince", "", "Require signatures only from this date (RFC 3339 or YYYY-MM-DD), tag, or commit hash onward. Earlier unsigned commits are informational. Required for all commits if empty.")
+	paths := flag.String("signaturePaths", "", "Comma separated glob patterns of sensitive paths, e.g., .github/workflows/**,go.mod. Commits changing them require a trusted signature.")
+	return func() *io.SignaturePolicy {
+		return ParseSignaturePolicy(*since, *paths)
+	}
+}
+
+// ParseSignaturePolicy returns the policy requiring signatures from since onward and trusted
+// signatures for the comma separated paths, or nil if all commits have to be signed.
+func ParseSignaturePolicy(since, paths string) *io.SignaturePolicy {
+	if since == "" && paths == "" {
+		return nil
+	}
+	policy := &io.SignaturePolicy{Since: since}
+	for _, p := range strings.Split(paths, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			policy.Paths = append(policy.Paths, p)
+		}
+	}
+	return policy
+}
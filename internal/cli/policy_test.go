@@ -0,0 +1,28 @@
+package cli
+
+import (
+	"reflect"
+	"testing"
+
+	"project-integrity-calculator/internal/io"
+)
+
+func TestParseSignaturePolicy(t *testing.T) {
+	tests := []struct {
+		name         string
+		since, paths string
+		want         *io.SignaturePolicy
+	}{
+		{"no policy", "", "", nil},
+		{"cutoff", "v1", "", &io.SignaturePolicy{Since: "v1"}},
+		{"paths", "", ".github/workflows/**, go.mod", &io.SignaturePolicy{Paths: []string{".github/workflows/**", "go.mod"}}},
+		{"empty paths", "2024-01-01", " ,go.mod,", &io.SignaturePolicy{Since: "2024-01-01", Paths: []string{"go.mod"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ParseSignaturePolicy(tt.since, tt.paths); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseSignaturePolicy(%q, %q) = %+v, want %+v", tt.since, tt.paths, got, tt.want)
+			}
+		})
+	}
+}
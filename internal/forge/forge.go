@@ -0,0 +1,48 @@
+package forge
+
+import (
+	"context"
+	"time"
+
+	"project-integrity-calculator/internal/gh"
+)
+
+type Kind string
+
+const (
+	GitHub Kind = "github"
+	GitLab Kind = "gitlab"
+	// Gitea and its fork Forgejo share the same API
+	Gitea Kind = "gitea"
+	// Gerrit changes are mapped to PRs
+	Gerrit Kind = "gerrit"
+	// Local repositories with a manifest of their PRs, no forge is accessed
+	Local Kind = "local"
+)
+
+// Forge provides the information about a single repository hosted on a code
+// forge like GitHub or GitLab. Pull requests of all forges are mapped to gh.PR,
+// so they can be processed by the same patch-id matching pipeline.
+// All requests to the forge are canceled if the given context is done.
+type Forge interface {
+	GetRepoInfo(ctx context.Context) (*gh.RepoInfo, error)
+	// GetPullRequests returns an iterator over all merged pull requests targeting branch.
+	// If since is not zero, only pull requests merged after since are returned.
+	GetPullRequests(ctx context.Context, branch string, since time.Time) *gh.PRIterator
+	GetForcePushInfo(ctx context.Context, branch string) (int, error)
+	// GitAuth returns the value of the HTTP Authorization header used by git
+	// to access the repository. It is empty for anonymous access.
+	GitAuth(ctx context.Context) (string, error)
+}
+
+// SigningKeySource is implemented by forges providing the public signing keys of the
+// contributors, so their signatures can be verified without trusting them.
+type SigningKeySource interface {
+	GetSigningKeys(ctx context.Context) (*gh.SigningKeys, error)
+}
+
+// SignatureSource is implemented by forges verifying the signatures of commits themselves.
+// Commits unknown to the forge are missing in the result.
+type SignatureSource interface {
+	GetCommitSignatures(ctx context.Context, hashes []string) (map[string]gh.CommitSignature, error)
+}
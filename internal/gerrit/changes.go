@@ -0,0 +1,198 @@
+package gerrit
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"project-integrity-calculator/internal/gh"
+)
+
+type account struct {
+	Username string   `json:"username"`
+	Email    string   `json:"email"`
+	Value    int      `json:"value"`
+	Tags     []string `json:"tags"` // e.g., SERVICE_USER for bots
+}
+
+type revision struct {
+	Number int    `json:"_number"`
+	Ref    string `json:"ref"`
+	Commit struct {
+		Parents []struct {
+			Commit string `json:"commit"`
+		} `json:"parents"`
+	} `json:"commit"`
+}
+
+type change struct {
+	Number          int                 `json:"_number"`
+	Subject         string              `json:"subject"`
+	Submitted       string              `json:"submitted"`
+	Owner           account             `json:"owner"`
+	Submitter       account             `json:"submitter"`
+	CurrentRevision string              `json:"current_revision"`
+	Revisions       map[string]revision `json:"revisions"`
+	Labels          map[string]struct {
+		All []account `json:"all"`
+	} `json:"labels"`
+	MoreChanges bool `json:"_more_changes"`
+}
+
+const pageSize = 100
+
+// quote quotes a value of a search operator, so it is matched exactly even if
+// it contains spaces or characters of the query syntax.
+func quote(value string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value) + `"`
+}
+
+// GetPullRequests returns an iterator over all merged changes targeting branch.
+// If since is not zero, only changes merged after since are returned.
+func (p *Project) GetPullRequests(ctx context.Context, branch string, since time.Time) *gh.PRIterator {
+	return gh.NewPRIterator(func(yield func([]gh.PR) bool) error {
+		q := fmt.Sprintf("status:merged project:%s branch:%s", quote(p.name), quote(branch))
+		if !since.IsZero() {
+			// after filters by the last update, which is never before the submission
+			q += fmt.Sprintf(" after:\"%s\"", since.UTC().Format("2006-01-02 15:04:05"))
+		}
+		query := url.Values{}
+		query.Set("q", q)
+		query.Set("n", strconv.Itoa(pageSize))
+		query["o"] = []string{"DETAILED_LABELS", "DETAILED_ACCOUNTS"}
+		if p.allPatchSets {
+			query["o"] = append(query["o"], "ALL_REVISIONS", "ALL_COMMITS")
+		} else {
+			query["o"] = append(query["o"], "CURRENT_REVISION", "CURRENT_COMMIT")
+		}
+
+		// Gerrit uses offsets for pagination and marks the last change
+		// of a page if there are more changes
+		for start := 0; ; start += pageSize {
+			query.Set("S", strconv.Itoa(start))
+			var changes []change
+			if err := p.get(ctx, p.endpoint("/changes/?"+query.Encode()), &changes); err != nil {
+				return fmt.Errorf("fetching changes (offset %d) failed: %w", start, err)
+			}
+
+			prs := make([]gh.PR, 0, len(changes))
+			for _, c := range changes {
+				pr, err := p.toPR(c)
+				if err != nil {
+					return err
+				}
+				if !since.IsZero() && !pr.MergedAfter(since) {
+					continue
+				}
+				prs = append(prs, pr)
+			}
+
+			if !yield(prs) {
+				return nil
+			}
+
+			if len(changes) == 0 || !changes[len(changes)-1].MoreChanges {
+				return nil
+			}
+		}
+	})
+}
+
+// toPR maps a change to the PR model of GitHub. The final patch set is the head
+// of the PR and earlier patch sets become revisions.
+func (p *Project) toPR(c change) (gh.PR, error) {
+	current, ok := c.Revisions[c.CurrentRevision]
+	if !ok {
+		return gh.PR{}, fmt.Errorf("current revision of change %d is missing", c.Number)
+	}
+
+	mergedAt, err := parseTimestamp(c.Submitted)
+	if err != nil {
+		return gh.PR{}, fmt.Errorf("invalid submit time of change %d: %w", c.Number, err)
+	}
+
+	pr := gh.PR{
+		BaseRefOid: parent(current),
+		HeadRefOid: c.CurrentRevision,
+		Number:     c.Number,
+		Title:      c.Subject,
+		State:      "MERGED",
+		// the submitted commit is not exposed, it is matched by its patch id
+		MergedAt: mergedAt,
+		Author:   toActor(c.Owner),
+		MergedBy: toActor(c.Submitter),
+		HeadRef:  current.Ref,
+	}
+
+	if p.allPatchSets {
+		for sha, r := range c.Revisions {
+			if sha == c.CurrentRevision {
+				continue
+			}
+			pr.Revisions = append(pr.Revisions, gh.Revision{
+				BaseRefOid: parent(r),
+				HeadRefOid: sha,
+				HeadRef:    r.Ref,
+			})
+		}
+		slices.SortFunc(pr.Revisions, func(a, b gh.Revision) int {
+			return c.Revisions[a.HeadRefOid].Number - c.Revisions[b.HeadRefOid].Number
+		})
+	}
+
+	pr.Reviews.Nodes = toReviews(c.Labels["Code-Review"].All)
+	return pr, nil
+}
+
+// toReviews maps the current Code-Review votes to reviews. Votes have no order,
+// so approvals are put last, as they allowed the change to be submitted.
+// Positive votes below the maximum of +2 are comments, as they don't approve the change.
+func toReviews(votes []account) []gh.Review {
+	var comments, changesRequested, approvals []gh.Review
+	for _, v := range votes {
+		review := gh.Review{Author: toActor(v)}
+		switch {
+		case v.Value >= 2:
+			review.State = "APPROVED"
+			approvals = append(approvals, review)
+		case v.Value < 0:
+			review.State = "CHANGES_REQUESTED"
+			changesRequested = append(changesRequested, review)
+		case v.Value == 1:
+			review.State = "COMMENTED"
+			comments = append(comments, review)
+		}
+	}
+	return slices.Concat(comments, changesRequested, approvals)
+}
+
+func parent(r revision) string {
+	if len(r.Commit.Parents) == 0 {
+		return ""
+	}
+	return r.Commit.Parents[0].Commit
+}
+
+func toActor(a account) gh.Actor {
+	t := "User"
+	if slices.Contains(a.Tags, "SERVICE_USER") {
+		t = "Bot"
+	}
+	login := a.Username
+	if login == "" {
+		login = a.Email
+	}
+	return gh.Actor{Login: login, Type: t}
+}
+
+// Gerrit timestamps are in UTC, e.g., 2024-01-31 12:00:00.000000000
+const timestampLayout = "2006-01-02 15:04:05.000000000"
+
+// parseTimestamp parses a Gerrit timestamp.
+func parseTimestamp(s string) (time.Time, error) {
+	return time.Parse(timestampLayout, s)
+}
@@ -0,0 +1,123 @@
+package gerrit
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"project-integrity-calculator/internal/gh"
+)
+
+// Project is a project hosted on Gerrit. It implements forge.Forge by mapping
+// merged changes to gh.PR. Gerrit changes consist of a single commit, which is
+// reviewed in one or more patch sets. The final patch set is the head of the PR,
+// earlier patch sets are mapped to revisions if allPatchSets is set.
+type Project struct {
+	client  *gh.Client
+	baseURL string
+	// name of the project, e.g., platform/build
+	name         string
+	auth         string
+	allPatchSets bool
+}
+
+// ClientConfig returns the configuration of a gh.Client for the Gerrit instance.
+// Gerrit only supports basic authentication with the HTTP password of the user,
+// so credentials are expected in the format username:password.
+func ClientConfig(credentials string, cache *gh.Cache) gh.ClientConfig {
+	return gh.ClientConfig{
+		Authorization: basicAuth(credentials),
+		Cache:         cache,
+	}
+}
+
+// NewProject creates a Project for the project name on the Gerrit instance at baseURL.
+// The credentials must be the same as used for the client, see ClientConfig.
+func NewProject(client *gh.Client, baseURL, name, credentials string, allPatchSets bool) *Project {
+	return &Project{
+		client:       client,
+		baseURL:      strings.TrimSuffix(baseURL, "/"),
+		name:         name,
+		auth:         basicAuth(credentials),
+		allPatchSets: allPatchSets,
+	}
+}
+
+func basicAuth(credentials string) string {
+	if credentials == "" {
+		return ""
+	}
+	return "Basic " + base64.StdEncoding.EncodeToString([]byte(credentials))
+}
+
+// endpoint returns the URL of path. Authenticated requests are prefixed with /a/.
+func (p *Project) endpoint(path string) string {
+	if p.auth != "" {
+		return p.baseURL + "/a" + path
+	}
+	return p.baseURL + path
+}
+
+// Gerrit prefixes all JSON responses to prevent cross-site script inclusion
+const xssiPrefix = ")]}'"
+
+// get executes a GET request and decodes the JSON response into result.
+func (p *Project) get(ctx context.Context, reqUrl string, result any) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", reqUrl, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer gh.CloseBody(resp)
+
+	body := bufio.NewReader(resp.Body)
+	prefix, err := body.Peek(len(xssiPrefix))
+	if err == nil && string(prefix) == xssiPrefix {
+		if _, err := body.Discard(len(xssiPrefix)); err != nil {
+			return err
+		}
+	}
+	if err := json.NewDecoder(body).Decode(result); err != nil {
+		return fmt.Errorf("failed to decode JSON response of %s: %w", reqUrl, err)
+	}
+	return nil
+}
+
+func (p *Project) GetRepoInfo(ctx context.Context) (*gh.RepoInfo, error) {
+	slog.Default().Info("Getting repo info from Gerrit", "project", p.name)
+
+	// HEAD of the project points to the default branch, e.g., refs/heads/master
+	var head string
+	if err := p.get(ctx, p.endpoint("/projects/"+url.PathEscape(p.name)+"/HEAD"), &head); err != nil {
+		return nil, err
+	}
+
+	// Gerrit neither reports languages nor stars
+	return &gh.RepoInfo{
+		CloneUrl:      p.endpoint("/" + p.name),
+		DefaultBranch: strings.TrimPrefix(head, "refs/heads/"),
+		Languages:     []string{},
+	}, nil
+}
+
+// GetForcePushInfo always returns zero, as Gerrit doesn't expose force pushes in its API.
+func (p *Project) GetForcePushInfo(ctx context.Context, branch string) (int, error) {
+	slog.Default().Warn("Force pushes can't be queried from Gerrit. Reporting zero force pushes.", "branch", branch)
+	return 0, nil
+}
+
+// GitAuth uses the same credentials as the REST API.
+func (p *Project) GitAuth(ctx context.Context) (string, error) {
+	return p.auth, nil
+}
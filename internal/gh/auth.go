@@ -0,0 +1,220 @@
+package gh
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// TokenSource provides the token used to authenticate requests to GitHub.
+type TokenSource interface {
+	Token(ctx context.Context) (string, error)
+}
+
+// StaticToken is a TokenSource for tokens which don't expire during a run,
+// e.g., personal access tokens.
+type StaticToken string
+
+func (t StaticToken) Token(ctx context.Context) (string, error) {
+	return string(t), nil
+}
+
+type AppConfig struct {
+	AppID   string
+	KeyPath string // path to the PEM encoded private key of the app
+	// optional, if zero the installation of the app for Owner/Repo is used
+	InstallationID int64
+	Owner, Repo    string
+}
+
+// AppTokenSource authenticates as installation of a GitHub App. It signs a JWT
+// with the private key of the app and exchanges it for an installation token.
+// Installation tokens expire after one hour and are renewed automatically.
+// See https://docs.github.com/en/apps/creating-github-apps/authenticating-with-a-github-app
+type AppTokenSource struct {
+	appID          string
+	key            *rsa.PrivateKey
+	installationID int64
+	owner, repo    string
+	restURL        string
+	httpClient     *http.Client
+
+	mu        sync.Mutex
+	token     string
+	expiresAt time.Time
+}
+
+// tokens are renewed if they expire within this margin
+const tokenExpiryMargin = 5 * time.Minute
+
+func NewAppTokenSource(config AppConfig, restURL string) (*AppTokenSource, error) {
+	if config.AppID == "" {
+		return nil, errors.New("app id is required")
+	}
+	if config.InstallationID == 0 && (config.Owner == "" || config.Repo == "") {
+		return nil, errors.New("installation id or owner and repo are required")
+	}
+
+	pemData, err := os.ReadFile(config.KeyPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read private key: %w", err)
+	}
+	key, err := parsePrivateKey(pemData)
+	if err != nil {
+		return nil, err
+	}
+
+	if restURL == "" {
+		restURL = DefaultRestURL
+	}
+
+	return &AppTokenSource{
+		appID:          config.AppID,
+		key:            key,
+		installationID: config.InstallationID,
+		owner:          config.Owner,
+		repo:           config.Repo,
+		restURL:        restURL,
+		httpClient:     &http.Client{Timeout: requestTimeout},
+	}, nil
+}
+
+func parsePrivateKey(pemData []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(pemData)
+	if block == nil {
+		return nil, errors.New("private key is not PEM encoded")
+	}
+
+	// GitHub issues PKCS #1 keys, but converted PKCS #8 keys work as well
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key: %w", err)
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("private key is not an RSA key")
+	}
+	return rsaKey, nil
+}
+
+// Token returns a valid installation token and renews it if it is about to expire.
+func (s *AppTokenSource) Token(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.token != "" && time.Until(s.expiresAt) > tokenExpiryMargin {
+		return s.token, nil
+	}
+
+	jwt, err := s.jwt()
+	if err != nil {
+		return "", err
+	}
+
+	if s.installationID == 0 {
+		id, err := s.getInstallationID(ctx, jwt)
+		if err != nil {
+			return "", err
+		}
+		s.installationID = id
+	}
+
+	var res struct {
+		Token     string    `json:"token"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+	url := s.restURL + "/app/installations/" + strconv.FormatInt(s.installationID, 10) + "/access_tokens"
+	if err := s.appRequest(ctx, "POST", url, jwt, &res); err != nil {
+		return "", fmt.Errorf("failed to create installation token: %w", err)
+	}
+
+	slog.Default().Info("Created installation token", "installation", s.installationID, "expires at", res.ExpiresAt)
+	s.token = res.Token
+	s.expiresAt = res.ExpiresAt
+	return s.token, nil
+}
+
+func (s *AppTokenSource) getInstallationID(ctx context.Context, jwt string) (int64, error) {
+	var res struct {
+		ID int64 `json:"id"`
+	}
+	url := s.restURL + "/repos/" + s.owner + "/" + s.repo + "/installation"
+	if err := s.appRequest(ctx, "GET", url, jwt, &res); err != nil {
+		return 0, fmt.Errorf("failed to get installation of app for %s/%s: %w", s.owner, s.repo, err)
+	}
+	return res.ID, nil
+}
+
+// appRequest executes a request authenticated as the app itself.
+func (s *AppTokenSource) appRequest(ctx context.Context, method, url, jwt string, result any) error {
+	req, err := http.NewRequestWithContext(ctx, method, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("Authorization", "Bearer "+jwt)
+
+	resp, err := s.httpClient.Do(req)
+	RequestCounter.Add(1)
+	if err != nil {
+		return err
+	}
+	defer CloseBody(resp)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("request to %s failed with status %s", url, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(result)
+}
+
+// jwt creates a JSON Web Token signed with the private key of the app.
+// The token is valid for ten minutes, the maximum allowed by GitHub.
+func (s *AppTokenSource) jwt() (string, error) {
+	now := time.Now()
+	header := map[string]string{"alg": "RS256", "typ": "JWT"}
+	claims := map[string]any{
+		// issued 60 seconds in the past to allow for clock drift
+		"iat": now.Add(-time.Minute).Unix(),
+		"exp": now.Add(9 * time.Minute).Unix(),
+		"iss": s.appID,
+	}
+
+	var buf bytes.Buffer
+	for i, part := range []any{header, claims} {
+		b, err := json.Marshal(part)
+		if err != nil {
+			return "", err
+		}
+		if i > 0 {
+			buf.WriteByte('.')
+		}
+		buf.WriteString(base64.RawURLEncoding.EncodeToString(b))
+	}
+
+	hash := sha256.Sum256(buf.Bytes())
+	sig, err := rsa.SignPKCS1v15(rand.Reader, s.key, crypto.SHA256, hash[:])
+	if err != nil {
+		return "", fmt.Errorf("failed to sign JWT: %w", err)
+	}
+	buf.WriteByte('.')
+	buf.WriteString(base64.RawURLEncoding.EncodeToString(sig))
+	return buf.String(), nil
+}
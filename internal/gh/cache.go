@@ -0,0 +1,165 @@
+package gh
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path"
+	"time"
+)
+
+type CacheMode string
+
+const (
+	// CacheRecord always queries the API and stores all responses in the cache.
+	CacheRecord CacheMode = "record"
+	// CacheReplay only uses cached responses and never queries the API.
+	CacheReplay CacheMode = "replay"
+	// CacheRefresh uses cached responses younger than MaxAge and queries the API otherwise.
+	CacheRefresh CacheMode = "refresh"
+)
+
+var ErrCacheMiss = errors.New("no cached response found")
+
+// Cache stores responses of the GitHub API on disk. Entries are content-addressed
+// by the hash of the request method, URL, and body. The body of GraphQL requests
+// contains the query and its variables, so they are part of the key as well.
+// The authorization header is not part of the key, so cached responses can be
+// replayed without a token.
+type Cache struct {
+	Dir    string
+	Mode   CacheMode
+	MaxAge time.Duration // only used in CacheRefresh mode
+}
+
+func ParseCacheMode(mode string) (CacheMode, error) {
+	switch CacheMode(mode) {
+	case CacheRecord, CacheReplay, CacheRefresh:
+		return CacheMode(mode), nil
+	default:
+		return "", fmt.Errorf("unknown cache mode %q. Must be one of record, replay, or refresh", mode)
+	}
+}
+
+type cacheEntry struct {
+	Method     string
+	URL        string
+	Created    time.Time
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+}
+
+// cacheKey returns the hash identifying req. It consumes the body of req
+// and replaces it with an identical one.
+func cacheKey(req *http.Request) (string, error) {
+	var body []byte
+	if req.Body != nil {
+		b, err := io.ReadAll(req.Body)
+		if err != nil {
+			return "", err
+		}
+		_ = req.Body.Close()
+		req.Body = io.NopCloser(bytes.NewReader(b))
+		body = b
+	}
+
+	h := sha256.New()
+	h.Write([]byte(req.Method + "\n" + req.URL.String() + "\n"))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func (c *Cache) path(key string) string {
+	return path.Join(c.Dir, key[:2], key+".json")
+}
+
+// get returns the cached response for key. In CacheRecord mode and for
+// outdated entries in CacheRefresh mode no response is returned.
+func (c *Cache) get(key string) (*http.Response, bool) {
+	if c.Mode == CacheRecord {
+		return nil, false
+	}
+
+	file, err := os.Open(c.path(key))
+	if err != nil {
+		return nil, false
+	}
+	defer func() {
+		if err := file.Close(); err != nil {
+			slog.Default().Warn("Failed to close cache file", "error", err)
+		}
+	}()
+
+	var entry cacheEntry
+	if err := json.NewDecoder(file).Decode(&entry); err != nil {
+		slog.Default().Warn("Failed to decode cache entry. Ignoring it.", "key", key, "error", err)
+		return nil, false
+	}
+
+	if c.Mode == CacheRefresh && c.MaxAge > 0 && time.Since(entry.Created) > c.MaxAge {
+		return nil, false
+	}
+
+	slog.Default().Debug("Using cached response", "url", entry.URL)
+	return &http.Response{
+		Status:        http.StatusText(entry.StatusCode),
+		StatusCode:    entry.StatusCode,
+		Header:        entry.Header,
+		Body:          io.NopCloser(bytes.NewReader(entry.Body)),
+		ContentLength: int64(len(entry.Body)),
+	}, true
+}
+
+// put stores the response to req with the given body for key.
+// Responses containing GraphQL errors are not stored, as the errors are
+// typically temporary, e.g., due to rate limits or timeouts.
+func (c *Cache) put(key string, req *http.Request, resp *http.Response, body []byte) error {
+	var errResp graphQLErrorResponse
+	if json.Unmarshal(body, &errResp) == nil && len(errResp.Errors) > 0 {
+		return nil
+	}
+
+	entry := cacheEntry{
+		Method:     req.Method,
+		URL:        req.URL.String(),
+		Created:    time.Now(),
+		StatusCode: resp.StatusCode,
+		Header:     http.Header{},
+		Body:       body,
+	}
+	// only keep headers required to process the response
+	for _, h := range []string{"Content-Type", "Link"} {
+		if v := resp.Header.Get(h); v != "" {
+			entry.Header.Set(h, v)
+		}
+	}
+
+	target := c.path(key)
+	if err := os.MkdirAll(path.Dir(target), os.ModePerm); err != nil {
+		return err
+	}
+
+	// write to a temporary file first so concurrent readers never see partial entries
+	tmp, err := os.CreateTemp(path.Dir(target), key+"-*.tmp")
+	if err != nil {
+		return err
+	}
+	if err := json.NewEncoder(tmp).Encode(&entry); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), target)
+}
@@ -0,0 +1,294 @@
+package gh
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Client is used for all requests to the GitHub API. It authenticates the requests,
+// waits if the rate limit is exhausted, and retries requests which failed due to
+// server errors or secondary rate limits.
+// A Client is safe for concurrent use and should be shared between all requests.
+type Client struct {
+	httpClient *http.Client
+	graphQLURL string
+	restURL    string
+	tokens     TokenSource // nil if requests are not authenticated
+	// static Authorization header used instead of tokens, e.g., for basic authentication
+	authorization string
+	cache         *Cache
+	maxRetries    int
+	baseDelay     time.Duration
+
+	mu sync.Mutex
+	// point in time until which no requests should be made
+	blockedUntil time.Time
+}
+
+type ClientConfig struct {
+	Token string
+	// optional, authenticates as GitHub App installation instead of using Token
+	App        *AppConfig
+	GraphQLURL string // optional, defaults to DefaultGraphQLURL
+	RestURL    string // optional, defaults to DefaultRestURL
+	Cache      *Cache // optional, responses are not cached if nil
+	// optional, sent as Authorization header instead of a bearer token
+	// for APIs which don't support bearer tokens, e.g., "Basic <credentials>"
+	Authorization string
+}
+
+// requestTimeout limits single requests, so hung connections don't block the analysis
+// until its deadline. Rate limits and retries are not included.
+const requestTimeout = 5 * time.Minute
+
+func NewClient(config ClientConfig) (*Client, error) {
+	graphQLURL := config.GraphQLURL
+	if graphQLURL == "" {
+		graphQLURL = DefaultGraphQLURL
+	}
+	restURL := strings.TrimSuffix(config.RestURL, "/")
+	if restURL == "" {
+		restURL = DefaultRestURL
+	}
+
+	var tokens TokenSource
+	if config.App != nil {
+		app, err := NewAppTokenSource(*config.App, restURL)
+		if err != nil {
+			return nil, err
+		}
+		tokens = app
+	} else if config.Token != "" {
+		tokens = StaticToken(config.Token)
+	}
+
+	return &Client{
+		httpClient:    &http.Client{Timeout: requestTimeout},
+		graphQLURL:    graphQLURL,
+		restURL:       restURL,
+		tokens:        tokens,
+		cache:         config.Cache,
+		authorization: config.Authorization,
+		maxRetries:    5,
+		baseDelay:     time.Second,
+	}, nil
+}
+
+// Do executes req and returns the response if its status code is 2xx.
+// The caller is responsible for closing the body of the response.
+// Waiting for the rate limit and retries stop if the context of req is done.
+// Requests are retried up to maxRetries times if they fail with a 5xx
+// status code, due to a rate limit, or due to a network error.
+// If a cache is configured, successful responses are read from and written to it.
+func (c *Client) Do(req *http.Request) (*http.Response, error) {
+	if c.cache == nil {
+		return c.do(req)
+	}
+
+	key, err := cacheKey(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp, ok := c.cache.get(key); ok {
+		return resp, nil
+	}
+	if c.cache.Mode == CacheReplay {
+		return nil, fmt.Errorf("%w for %s %s", ErrCacheMiss, req.Method, req.URL)
+	}
+
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, err
+	}
+	body, err := io.ReadAll(resp.Body)
+	CloseBody(resp)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	if err := c.cache.put(key, req, resp, body); err != nil {
+		slog.Default().Warn("Failed to cache response", "url", req.URL, "error", err)
+	}
+	return resp, nil
+}
+
+func (c *Client) do(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	for attempt := 0; ; attempt++ {
+		if err := c.waitForRateLimit(ctx); err != nil {
+			return nil, err
+		}
+
+		// the token is renewed before every attempt, as it might expire while waiting
+		if c.authorization != "" {
+			req.Header.Set("Authorization", c.authorization)
+		} else if c.tokens != nil {
+			token, err := c.tokens.Token(ctx)
+			if err != nil {
+				return nil, err
+			}
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+
+		if attempt > 0 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req.Body = body
+		}
+
+		resp, err := c.httpClient.Do(req)
+		RequestCounter.Add(1)
+		if err != nil {
+			// requests canceled by the caller are not retried
+			if ctx.Err() != nil {
+				return nil, err
+			}
+			if attempt >= c.maxRetries {
+				return nil, fmt.Errorf("HTTP request failed after %d retries: %w", attempt, err)
+			}
+			delay := c.backoff(attempt)
+			slog.Default().Warn("HTTP request failed. Retrying.", "url", req.URL, "delay", delay, "err", err)
+			if err := sleep(ctx, delay); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		c.updateRateLimit(resp)
+
+		if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+			return resp, nil
+		}
+
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		CloseBody(resp)
+
+		delay, retry := c.retryDelay(resp, body, attempt)
+		if !retry || attempt >= c.maxRetries {
+			return nil, fmt.Errorf("request to %s failed with status %s: %s", req.URL, resp.Status, strings.TrimSpace(string(body)))
+		}
+
+		slog.Default().Warn("GitHub request failed. Retrying.", "url", req.URL, "status", resp.StatusCode, "delay", delay)
+		c.block(delay)
+	}
+}
+
+// GitAuth returns the value of the HTTP Authorization header used by git to access
+// repositories of the GitHub instance. It is empty if no token is configured.
+// The value changes if the token has been renewed, so it should be requested
+// for every git command.
+func (c *Client) GitAuth(ctx context.Context) (string, error) {
+	if c.tokens == nil {
+		return "", nil
+	}
+	token, err := c.tokens.Token(ctx)
+	if err != nil {
+		return "", err
+	}
+	return "Basic " + base64.StdEncoding.EncodeToString([]byte("x-access-token:"+token)), nil
+}
+
+// waitForRateLimit blocks until the rate limit is reset or the retry delay has passed.
+// It returns the error of ctx if ctx is done before.
+func (c *Client) waitForRateLimit(ctx context.Context) error {
+	c.mu.Lock()
+	wait := time.Until(c.blockedUntil)
+	c.mu.Unlock()
+
+	if wait > 0 {
+		slog.Default().Info("Waiting for rate limit or retry delay.", "duration", wait)
+		return sleep(ctx, wait)
+	}
+	return ctx.Err()
+}
+
+// sleep pauses for d or until ctx is done, in which case the error of ctx is returned.
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// block prevents all requests for the given duration.
+func (c *Client) block(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	until := time.Now().Add(d)
+	if until.After(c.blockedUntil) {
+		c.blockedUntil = until
+	}
+}
+
+// updateRateLimit reads the X-RateLimit-* headers and blocks further
+// requests until the reset if no requests remain.
+func (c *Client) updateRateLimit(resp *http.Response) {
+	if resp.Header.Get("X-RateLimit-Remaining") != "0" {
+		return
+	}
+	reset, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64)
+	if err != nil {
+		return
+	}
+	// add a second to make up for clock differences
+	c.block(time.Until(time.Unix(reset, 0)) + time.Second)
+}
+
+// retryDelay decides if a failed request should be retried and how long to wait before.
+// See https://docs.github.com/en/rest/using-the-rest-api/rate-limits-for-the-rest-api
+func (c *Client) retryDelay(resp *http.Response, body []byte, attempt int) (time.Duration, bool) {
+	if resp.StatusCode >= 500 {
+		return c.backoff(attempt), true
+	}
+
+	if resp.StatusCode != http.StatusForbidden && resp.StatusCode != http.StatusTooManyRequests {
+		return 0, false
+	}
+
+	if retryAfter, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+		return time.Duration(retryAfter) * time.Second, true
+	}
+
+	if resp.Header.Get("X-RateLimit-Remaining") == "0" {
+		// updateRateLimit already blocks until the reset
+		return 0, true
+	}
+
+	// secondary rate limits without further information require to wait at least a minute
+	if strings.Contains(strings.ToLower(string(body)), "secondary rate limit") ||
+		strings.Contains(strings.ToLower(string(body)), "abuse") {
+		return time.Minute + c.backoff(attempt), true
+	}
+
+	return 0, false
+}
+
+// backoff returns an exponentially growing delay with random jitter.
+func (c *Client) backoff(attempt int) time.Duration {
+	maxDelay := c.baseDelay << attempt
+	return maxDelay/2 + rand.N(maxDelay/2+1)
+}
+
+// CloseBody closes the body of resp and logs failures, which can't be handled.
+func CloseBody(resp *http.Response) {
+	if err := resp.Body.Close(); err != nil {
+		slog.Default().Warn("Failed to close response body", "error", err)
+	}
+}
@@ -0,0 +1,45 @@
+package gh
+
+import (
+	"context"
+	"time"
+)
+
+// Repository binds a Client to a single GitHub repository.
+// It implements forge.Forge.
+type Repository struct {
+	client      *Client
+	owner, name string
+}
+
+func (c *Client) Repository(owner, name string) *Repository {
+	return &Repository{
+		client: c,
+		owner:  owner,
+		name:   name,
+	}
+}
+
+func (r *Repository) GetRepoInfo(ctx context.Context) (*RepoInfo, error) {
+	return r.client.GetRepoInfo(ctx, r.owner, r.name)
+}
+
+func (r *Repository) GetPullRequests(ctx context.Context, branch string, since time.Time) *PRIterator {
+	return r.client.GetPullRequests(ctx, r.owner, r.name, branch, since)
+}
+
+func (r *Repository) GetForcePushInfo(ctx context.Context, branch string) (int, error) {
+	return r.client.GetForcePushInfo(ctx, r.owner, r.name, branch)
+}
+
+func (r *Repository) GitAuth(ctx context.Context) (string, error) {
+	return r.client.GitAuth(ctx)
+}
+
+func (r *Repository) GetSigningKeys(ctx context.Context) (*SigningKeys, error) {
+	return r.client.GetSigningKeys(ctx, r.owner, r.name)
+}
+
+func (r *Repository) GetCommitSignatures(ctx context.Context, hashes []string) (map[string]CommitSignature, error) {
+	return r.client.GetCommitSignatures(ctx, r.owner, r.name, hashes)
+}
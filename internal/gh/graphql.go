@@ -2,11 +2,15 @@ package gh
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
-	"iter"
+	"io"
 	"log/slog"
 	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
 )
 
 type GraphQLRequest struct {
@@ -38,13 +42,57 @@ type PR struct {
 	Title       string      `json:"title"`
 	State       string      `json:"state"`
 	MergeCommit MergeCommit `json:"mergeCommit"`
-	MergedAt    string      `json:"mergedAt"` // An ISO-8601 encoded UTC date string.
+	MergedAt    time.Time   `json:"mergedAt"`
+	UpdatedAt   time.Time   `json:"updatedAt,omitzero"`
+	Author      Actor       `json:"author"`
+	MergedBy    Actor       `json:"mergedBy"`
 	Reviews     struct {
-		Nodes []struct {
-			State string `json:"state"`
-		} `json:"nodes"`
+		Nodes    []Review   `json:"nodes"`
 		PageInfo Pagination `json:"pageInfo"`
 	} `json:"reviews"`
+	// HeadRef is the fully qualified ref of the PR head on the remote, e.g., refs/pull/1/head.
+	// It is fetched to access the commits of the PR. Commits of PRs without HeadRef
+	// must already be present in the local repository.
+	HeadRef string `json:"headRef,omitempty"`
+	// SquashCommitOid is set by forges which create a squash commit in
+	// addition to the merge commit, like GitLab.
+	SquashCommitOid string `json:"squashCommitOid,omitempty"`
+	// Revisions are earlier versions of the PR, e.g., the patch sets of a Gerrit change.
+	// Their commits are considered part of the PR as well.
+	Revisions []Revision `json:"revisions,omitempty"`
+	// ReviewsIncomplete is set if not all reviews could be fetched, so the
+	// review status of the PR may be wrong.
+	ReviewsIncomplete bool `json:"reviewsIncomplete,omitempty"`
+}
+
+// Revision is a version of a PR which has been replaced by a later one.
+type Revision struct {
+	BaseRefOid string `json:"baseRefOid"`
+	HeadRefOid string `json:"headRefOid"`
+	HeadRef    string `json:"headRef,omitempty"`
+}
+
+// MergedAfter reports whether the PR has been merged after t. PRs without
+// a merge time are considered to be merged after t.
+func (pr PR) MergedAfter(t time.Time) bool {
+	return pr.MergedAt.IsZero() || pr.MergedAt.After(t)
+}
+
+type Review struct {
+	State  string `json:"state"`
+	Author Actor  `json:"author"`
+}
+
+// Actor is a user, bot, or app interacting with GitHub.
+// Login is empty if the account has been deleted.
+type Actor struct {
+	Login string `json:"login"`
+	Type  string `json:"__typename"` // e.g., User, Bot, Organization
+}
+
+// IsBot reports whether the actor is a bot or app account.
+func (a Actor) IsBot() bool {
+	return a.Type == "Bot" || strings.HasSuffix(a.Login, "[bot]")
 }
 
 type MergeCommit struct {
@@ -70,9 +118,9 @@ query ($owner: String!, $name: String!) {
 `
 
 const initialPRQuery = `
-query ($owner: String!, $name: String!, $branch: String!) {
+query ($owner: String!, $name: String!, $branch: String!, $order: IssueOrder!) {
 	repository(owner: $owner, name: $name) {
-		pullRequests(first: 100, states: MERGED, baseRefName: $branch) {
+		pullRequests(first: 100, states: MERGED, baseRefName: $branch, orderBy: $order) {
 			nodes {
 				mergeCommit {
 		            id
@@ -80,14 +128,27 @@ query ($owner: String!, $name: String!, $branch: String!) {
 		            message
 		        }
 				mergedAt
+				updatedAt
 				baseRefOid
         		headRefOid
 				number
 				title
 				state
+				author {
+					login
+					__typename
+				}
+				mergedBy {
+					login
+					__typename
+				}
 				reviews(first: 100) {
 				nodes {
 					state
+					author {
+						login
+						__typename
+					}
 				}
 				pageInfo {
 					hasNextPage
@@ -107,9 +168,9 @@ query ($owner: String!, $name: String!, $branch: String!) {
 `
 
 const paginatedPRQuery = `
-query ($owner: String!, $name: String!, $branch: String!, $after: String!) {
+query ($owner: String!, $name: String!, $branch: String!, $order: IssueOrder!, $after: String!) {
 repository(owner: $owner, name: $name) {
-	pullRequests(first: 100, states: MERGED, baseRefName: $branch, after: $after) {
+	pullRequests(first: 100, states: MERGED, baseRefName: $branch, orderBy: $order, after: $after) {
 		nodes {
 			mergeCommit {
 	            id
@@ -117,14 +178,27 @@ repository(owner: $owner, name: $name) {
 	            message
 	        }
 			mergedAt
+			updatedAt
 			baseRefOid
    		    headRefOid
 			number
 			title
 			state
+			author {
+				login
+				__typename
+			}
+			mergedBy {
+				login
+				__typename
+			}
 			reviews(first: 100) {
 			nodes {
 				state
+				author {
+					login
+					__typename
+				}
 			}
 			pageInfo {
 				hasNextPage
@@ -143,12 +217,68 @@ repository(owner: $owner, name: $name) {
 }
 `
 
-const URL = "https://api.github.com/graphql"
+const paginatedReviewQuery = `
+query ($owner: String!, $name: String!, $number: Int!, $after: String!) {
+	repository(owner: $owner, name: $name) {
+		pullRequest(number: $number) {
+			reviews(first: 100, after: $after) {
+				nodes {
+					state
+					author {
+						login
+						__typename
+					}
+				}
+				pageInfo {
+					hasNextPage
+					startCursor
+					endCursor
+				}
+			}
+		}
+	}
+}
+`
+
+type ReviewResponse struct {
+	Data struct {
+		Repository struct {
+			PullRequest struct {
+				Reviews struct {
+					Nodes    []Review   `json:"nodes"`
+					PageInfo Pagination `json:"pageInfo"`
+				} `json:"reviews"`
+			} `json:"pullRequest"`
+		} `json:"repository"`
+	} `json:"data"`
+}
+
+// MaxReviewRequests limits the number of additional requests made to
+// fetch the reviews of a single PR with more than 100 reviews.
+// Reviews beyond this limit are ignored.
+const MaxReviewRequests = 10
 
-var RequestCounter = 0
+// DefaultGraphQLURL is the GraphQL endpoint of github.com. GitHub Enterprise
+// Server instances serve it at https://<host>/api/graphql.
+const DefaultGraphQLURL = "https://api.github.com/graphql"
+
+// RequestCounter counts the requests made to the API by all clients.
+var RequestCounter atomic.Int64
+
+type GraphQLError struct {
+	Type    string `json:"type"`
+	Message string `json:"message"`
+	Path    []any  `json:"path"`
+}
+
+type graphQLErrorResponse struct {
+	Errors []GraphQLError `json:"errors"`
+}
 
 // Helper function to execute a GraphQL request.
-func executeGraphQLRequest(client *http.Client, url, token, query string, variables map[string]any, result any) error {
+// Errors reported in the errors array of the response are returned as error.
+// Requests which failed due to the rate limit are retried.
+func (c *Client) executeGraphQLRequest(ctx context.Context, query string, variables map[string]any, result any) error {
 	reqPayload := GraphQLRequest{
 		Query:     query,
 		Variables: variables,
@@ -159,30 +289,61 @@ func executeGraphQLRequest(client *http.Client, url, token, query string, variab
 		return fmt.Errorf("failed to marshal request: %v", err)
 	}
 
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
-	if err != nil {
-		return fmt.Errorf("failed to create HTTP request: %v", err)
-	}
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+token)
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, "POST", c.graphQLURL, bytes.NewBuffer(jsonData))
+		if err != nil {
+			return fmt.Errorf("failed to create HTTP request: %v", err)
+		}
+		req.Header.Set("Content-Type", "application/json")
 
-	resp, err := client.Do(req)
-	RequestCounter++
-	if err != nil {
-		return fmt.Errorf("HTTP request failed: %v", err)
-	}
-	defer func() {
-		if err := resp.Body.Close(); err != nil {
-			// Log the error but don't return it to avoid masking the original error
-			fmt.Printf("Warning: failed to close response body: %v\n", err)
+		resp, err := c.Do(req)
+		if err != nil {
+			return fmt.Errorf("HTTP request failed: %w", err)
+		}
+
+		body, err := io.ReadAll(resp.Body)
+		CloseBody(resp)
+		if err != nil {
+			return fmt.Errorf("failed to read response: %w", err)
+		}
+
+		var errResp graphQLErrorResponse
+		if err := json.Unmarshal(body, &errResp); err != nil {
+			return fmt.Errorf("failed to decode JSON response: %v", err)
+		}
+		if len(errResp.Errors) > 0 {
+			if errResp.Errors[0].Type == "RATE_LIMITED" && attempt < c.maxRetries {
+				// the rate limit headers of the response already block the client until the reset
+				slog.Default().Warn("GraphQL rate limit exceeded. Retrying.", "attempt", attempt)
+				c.block(c.backoff(attempt))
+				continue
+			}
+			return graphQLErrors(errResp.Errors)
+		}
+
+		if err := json.Unmarshal(body, result); err != nil {
+			return fmt.Errorf("failed to decode JSON response: %v", err)
 		}
-	}()
+		return nil
+	}
+}
+
+// Query executes a GraphQL query against the configured GraphQL endpoint
+// and decodes the response into result.
+func (c *Client) Query(ctx context.Context, query string, variables map[string]any, result any) error {
+	return c.executeGraphQLRequest(ctx, query, variables, result)
+}
 
-	decoder := json.NewDecoder(resp.Body)
-	if err := decoder.Decode(result); err != nil {
-		return fmt.Errorf("failed to decode JSON response: %v", err)
+func graphQLErrors(errs []GraphQLError) error {
+	msgs := make([]string, 0, len(errs))
+	for _, e := range errs {
+		if e.Type != "" {
+			msgs = append(msgs, e.Type+": "+e.Message)
+		} else {
+			msgs = append(msgs, e.Message)
+		}
 	}
-	return nil
+	return fmt.Errorf("GraphQL request failed: %s", strings.Join(msgs, "; "))
 }
 
 type RepoInfo struct {
@@ -209,17 +370,16 @@ type RepoInfoResponse struct {
 	} `json:"data"`
 }
 
-func GetRepoInfo(owner, repo, token string) (*RepoInfo, error) {
+func (c *Client) GetRepoInfo(ctx context.Context, owner, repo string) (*RepoInfo, error) {
 	slog.Default().Info("Getting repo info")
 	variables := map[string]any{
 		"owner": owner,
 		"name":  repo,
 	}
 
-	client := &http.Client{}
 	var repoInfoRes RepoInfoResponse
 
-	err := executeGraphQLRequest(client, URL, token, repoDetailsQuery, variables, &repoInfoRes)
+	err := c.executeGraphQLRequest(ctx, repoDetailsQuery, variables, &repoInfoRes)
 	if err != nil {
 		slog.Default().Error("Graphql request failed", "err", err)
 		return nil, err
@@ -238,40 +398,52 @@ func GetRepoInfo(owner, repo, token string) (*RepoInfo, error) {
 	}, nil
 }
 
-func GetPullRequests(owner, repo, branch, token string) iter.Seq[[]PR] {
-	variables := map[string]any{
-		"owner":  owner,
-		"name":   repo,
-		"branch": branch,
-	}
-
-	client := &http.Client{}
-
-	var initialResp PrReviewResponse
-	if err := executeGraphQLRequest(client, URL, token, initialPRQuery, variables, &initialResp); err != nil {
-		// Return a nil sequence and the error
-		return func(yield func([]PR) bool) {}
-	}
-
-	// Define the iterator function
-	return func(yield func([]PR) bool) {
+// GetPullRequests returns an iterator over all merged PRs of branch. The PRs are
+// fetched lazily page by page. If a request fails, the iteration stops and
+// the error is reported by the Err method of the iterator.
+// If since is not zero, only PRs merged after since are returned. To not page through
+// all PRs, they are ordered by their last update, which is never before their merge.
+// The iteration stops with the error of ctx if ctx is done.
+func (c *Client) GetPullRequests(ctx context.Context, owner, repo, branch string, since time.Time) *PRIterator {
+	return NewPRIterator(func(yield func([]PR) bool) error {
 		slog.Default().Debug("Iterator started.")
-		// Use the already fetched initial response
-		currentResp := initialResp
+		order := map[string]string{"field": "CREATED_AT", "direction": "ASC"}
+		if !since.IsZero() {
+			order = map[string]string{"field": "UPDATED_AT", "direction": "DESC"}
+		}
+		variables := map[string]any{
+			"owner":  owner,
+			"name":   repo,
+			"branch": branch,
+			"order":  order,
+		}
+
+		var currentResp PrReviewResponse
+		if err := c.executeGraphQLRequest(ctx, initialPRQuery, variables, &currentResp); err != nil {
+			return fmt.Errorf("fetching first page of PRs failed: %w", err)
+		}
 
 		// Loop indefinitely, relying on break conditions
 		for {
+			prs := currentResp.Data.Repository.PullRequests.Nodes
+			done := false
+			if !since.IsZero() {
+				prs, done = mergedSince(prs, since)
+			}
+			c.getRemainingReviews(ctx, owner, repo, prs)
+			setHeadRefs(prs)
+
 			// Yield items from the current page
-			if !yield(currentResp.Data.Repository.PullRequests.Nodes) {
+			if !yield(prs) {
 				slog.Default().Debug("Iterator stopping early due to yield returning false.")
-				return // Stop iteration if yield returns false
+				return nil // Stop iteration if yield returns false
 			}
 			slog.Default().Debug("Finished yielding PRs from current page.")
 
 			// Check if there's a next page
-			if !currentResp.Data.Repository.PullRequests.PageInfo.HasNextPage {
+			if done || !currentResp.Data.Repository.PullRequests.PageInfo.HasNextPage {
 				slog.Default().Debug("No next page. Iterator finished.")
-				break // Exit loop if no more pages
+				return nil // Exit loop if no more pages
 			}
 
 			// Prepare for the next paginated request
@@ -280,14 +452,69 @@ func GetPullRequests(owner, repo, branch, token string) iter.Seq[[]PR] {
 			var paginatedResp PrReviewResponse
 
 			// Execute the paginated query
-			if err := executeGraphQLRequest(client, URL, token, paginatedPRQuery, variables, &paginatedResp); err != nil {
-				// Log the error (optional) and stop iteration
-				fmt.Printf("Error fetching next page (cursor %v): %v. Iterator stopping.\n", variables["after"], err)
-				break // Exit loop on error during pagination
+			if err := c.executeGraphQLRequest(ctx, paginatedPRQuery, variables, &paginatedResp); err != nil {
+				return fmt.Errorf("fetching next page of PRs (cursor %v) failed: %w", variables["after"], err)
 			}
 			slog.Default().Debug("Next page fetched successfully.")
 			currentResp = paginatedResp // Update currentResp for the next iteration
 		}
-		slog.Default().Debug("Iterator function finished.")
+	})
+}
+
+// mergedSince returns the PRs merged after since of a page ordered by the last update.
+// done is true if no later page can contain PRs merged after since.
+func mergedSince(prs []PR, since time.Time) (res []PR, done bool) {
+	res = make([]PR, 0, len(prs))
+	for _, pr := range prs {
+		if pr.MergedAfter(since) {
+			res = append(res, pr)
+		}
+	}
+	if len(prs) > 0 {
+		updatedAt := prs[len(prs)-1].UpdatedAt
+		done = !updatedAt.IsZero() && !updatedAt.After(since)
+	}
+	return res, done
+}
+
+func setHeadRefs(prs []PR) {
+	for i := range prs {
+		prs[i].HeadRef = fmt.Sprintf("refs/pull/%d/head", prs[i].Number)
+	}
+}
+
+// getRemainingReviews fetches the reviews of all PRs which have more reviews than
+// returned by the initial PR query. For each PR at most MaxReviewRequests
+// additional requests are made. PRs whose reviews couldn't be fetched completely
+// are marked with ReviewsIncomplete.
+func (c *Client) getRemainingReviews(ctx context.Context, owner, repo string, prs []PR) {
+	for i := range prs {
+		pr := &prs[i]
+		requests := 0
+		for pr.Reviews.PageInfo.HasNextPage {
+			if requests >= MaxReviewRequests {
+				slog.Default().Warn("Reached max number of review requests. Remaining reviews are ignored.", "pr", pr.Number, "reviews", len(pr.Reviews.Nodes))
+				pr.ReviewsIncomplete = true
+				break
+			}
+			requests++
+
+			variables := map[string]any{
+				"owner":  owner,
+				"name":   repo,
+				"number": pr.Number,
+				"after":  pr.Reviews.PageInfo.EndCursor,
+			}
+			var resp ReviewResponse
+			if err := c.executeGraphQLRequest(ctx, paginatedReviewQuery, variables, &resp); err != nil {
+				slog.Default().Warn("Fetching reviews failed. Remaining reviews are ignored.", "pr", pr.Number, "err", err)
+				pr.ReviewsIncomplete = true
+				break
+			}
+
+			reviews := resp.Data.Repository.PullRequest.Reviews
+			pr.Reviews.Nodes = append(pr.Reviews.Nodes, reviews.Nodes...)
+			pr.Reviews.PageInfo = reviews.PageInfo
+		}
 	}
 }
@@ -0,0 +1,29 @@
+package gh
+
+import "iter"
+
+// PRIterator iterates over pages of merged PRs. The iteration stops at the
+// first error, which is reported by Err once the iteration has finished.
+type PRIterator struct {
+	pages func(yield func([]PR) bool) error
+	err   error
+}
+
+// NewPRIterator creates a PRIterator from pages. pages yields all pages
+// and returns the error that stopped the iteration, if any.
+func NewPRIterator(pages func(yield func([]PR) bool) error) *PRIterator {
+	return &PRIterator{pages: pages}
+}
+
+// All returns a sequence of all pages. The sequence should only be used once.
+func (it *PRIterator) All() iter.Seq[[]PR] {
+	return func(yield func([]PR) bool) {
+		it.err = it.pages(yield)
+	}
+}
+
+// Err returns the error that stopped the iteration. It must only be
+// called after the iteration has finished.
+func (it *PRIterator) Err() error {
+	return it.err
+}
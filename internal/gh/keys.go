@@ -0,0 +1,82 @@
+package gh
+
+import (
+	"context"
+	"log/slog"
+	"net/url"
+)
+
+// SigningKeys are the public keys contributors sign their commits with.
+type SigningKeys struct {
+	// ASCII armored OpenPGP keys
+	GPG []string
+	// SSH keys in the authorized_keys format, e.g., "ssh-ed25519 AAAA..."
+	SSH []string
+}
+
+type contributor struct {
+	Login string `json:"login"`
+	Type  string `json:"type"`
+}
+
+type gpgKey struct {
+	RawKey string `json:"raw_key"`
+}
+
+type sshSigningKey struct {
+	Key string `json:"key"`
+}
+
+// GetSigningKeys returns the public GPG and SSH signing keys of all contributors of the repository.
+// Contributors whose keys can't be queried are skipped.
+func (c *Client) GetSigningKeys(ctx context.Context, owner, repo string) (*SigningKeys, error) {
+	logger := slog.Default()
+
+	var contributors []contributor
+	next := c.restURL + "/repos/" + owner + "/" + repo + "/contributors?per_page=100"
+	for next != "" {
+		var page []contributor
+		var err error
+		next, err = c.GetJSON(ctx, next, &page)
+		if err != nil {
+			return nil, err
+		}
+		contributors = append(contributors, page...)
+	}
+
+	keys := &SigningKeys{}
+	for _, contrib := range contributors {
+		if contrib.Type == "Bot" || contrib.Login == "" {
+			continue
+		}
+		userURL := c.restURL + "/users/" + url.PathEscape(contrib.Login)
+
+		var gpgKeys []gpgKey
+		if _, err := c.GetJSON(ctx, userURL+"/gpg_keys?per_page=100", &gpgKeys); err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			logger.Warn("Get GPG keys of contributor failed", "login", contrib.Login, "err", err)
+		}
+		for _, k := range gpgKeys {
+			// keys uploaded before GitHub stored them have no raw key
+			if k.RawKey != "" {
+				keys.GPG = append(keys.GPG, k.RawKey)
+			}
+		}
+
+		var sshKeys []sshSigningKey
+		if _, err := c.GetJSON(ctx, userURL+"/ssh_signing_keys?per_page=100", &sshKeys); err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			logger.Warn("Get SSH signing keys of contributor failed", "login", contrib.Login, "err", err)
+		}
+		for _, k := range sshKeys {
+			keys.SSH = append(keys.SSH, k.Key)
+		}
+	}
+	logger.Info("Signing keys of contributors", "contributors", len(contributors), "gpg keys", len(keys.GPG), "ssh keys", len(keys.SSH))
+
+	return keys, nil
+}
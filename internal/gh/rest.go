@@ -1,7 +1,9 @@
 package gh
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
 	"io"
 	"log/slog"
 	"net/http"
@@ -9,16 +11,18 @@ import (
 	"regexp"
 )
 
-const baseURL = "https://api.github.com"
+// DefaultRestURL is the REST endpoint of github.com. GitHub Enterprise
+// Server instances serve it at https://<host>/api/v3.
+const DefaultRestURL = "https://api.github.com"
 
 type Result struct {
 	Entry string
 }
 
 // Create an HTTP request using the parameters, token, method.
-func createHttpRequest(reqUrl, requestMethod string, requestBody io.ReadCloser, queryParameters, headerParameters map[string]string) (*http.Request, error) {
+func createHttpRequest(ctx context.Context, reqUrl, requestMethod string, requestBody io.ReadCloser, queryParameters, headerParameters map[string]string) (*http.Request, error) {
 
-	req, err := http.NewRequest(requestMethod, reqUrl, requestBody)
+	req, err := http.NewRequestWithContext(ctx, requestMethod, reqUrl, requestBody)
 	if err != nil {
 		return req, err
 	}
@@ -70,10 +74,10 @@ func updateHttpRequest(reqUrl, requestMethod string, requestBody io.ReadCloser,
 }
 
 // GetForcePushInfo Entry point for the Force Push processing, setting the url, query parameters and creating the client.
-func GetForcePushInfo(owner, repo, token, branch string) (int, error) {
+func (c *Client) GetForcePushInfo(ctx context.Context, owner, repo, branch string) (int, error) {
 
 	slog.Default().Info("Getting repo info - getForcePushInfo method")
-	repoActivityUrl := baseURL + "/repos/" + owner + "/" + repo + "/activity"
+	repoActivityUrl := c.restURL + "/repos/" + owner + "/" + repo + "/activity"
 
 	queryParameters := map[string]string{
 		"per_page":      "100",
@@ -82,13 +86,10 @@ func GetForcePushInfo(owner, repo, token, branch string) (int, error) {
 	}
 
 	headerParameters := map[string]string{
-		"Content-Type":  "application/json",
-		"Authorization": "Bearer " + token,
+		"Content-Type": "application/json",
 	}
 
-	client := &http.Client{}
-
-	numberForcePush, err := processForcePushRequest(client, repoActivityUrl, queryParameters, headerParameters)
+	numberForcePush, err := processForcePushRequest(ctx, c, repoActivityUrl, queryParameters, headerParameters)
 	if err != nil {
 		slog.Default().Error("Getting repo info falied - %v getForcePushInfo method", "error", err)
 		return 0, err
@@ -98,10 +99,9 @@ func GetForcePushInfo(owner, repo, token, branch string) (int, error) {
 }
 
 // Execute the HTTP request and return response
-func executeHTTPRequest(client *http.Client, req *http.Request) (*http.Response, error) {
+func executeHTTPRequest(client *Client, req *http.Request) (*http.Response, error) {
 
 	resp, err := client.Do(req)
-	RequestCounter++
 	if err != nil {
 		return nil, err
 	}
@@ -110,13 +110,13 @@ func executeHTTPRequest(client *http.Client, req *http.Request) (*http.Response,
 }
 
 // Handle calls to other methods (createHTTPRequest, executeHTTPRequest, processHttpResponse) are handled, and a loop is added so that requests are processed till there no rel = "next"
-func processForcePushRequest(client *http.Client, repoActivityUrl string, queryParameters, headerParameters map[string]string) (int, error) {
+func processForcePushRequest(ctx context.Context, client *Client, repoActivityUrl string, queryParameters, headerParameters map[string]string) (int, error) {
 
 	numberForcePush := 0
 	hasNext := true
 
 	// Create an initial HTTP Request and set header and query parameters
-	httpReq, err := createHttpRequest(repoActivityUrl, "GET", nil, queryParameters, headerParameters)
+	httpReq, err := createHttpRequest(ctx, repoActivityUrl, "GET", nil, queryParameters, headerParameters)
 	if err != nil {
 		slog.Default().Error("Failed to create HTTP request: %v", "error", err)
 		return 0, err
@@ -213,9 +213,46 @@ func checkIfNextPageExists(resp *http.Response) (bool, error) {
 func getNextURL(resp *http.Response) (string, error) {
 
 	linkHeader := resp.Header.Get("Link")
-	nextUrl := "<([^{}]*)>; rel=\"next\""
+	nextUrl := "<([^<>]*)>; rel=\"next\""
 	nextPatternMatch := regexp.MustCompile(nextUrl)
 	findString := nextPatternMatch.FindString(linkHeader)
 
 	return findString[1 : len(findString)-13], nil
 }
+
+// NextPage returns the URL of the next page referenced in the Link header of resp.
+// It returns false if there is no next page.
+func NextPage(resp *http.Response) (string, bool) {
+	hasNext, err := checkIfNextPageExists(resp)
+	if err != nil || !hasNext {
+		return "", false
+	}
+	next, err := getNextURL(resp)
+	if err != nil || next == "" {
+		return "", false
+	}
+	return next, true
+}
+
+// GetJSON executes a GET request, decodes the JSON response into result, and returns
+// the URL of the next page referenced in the Link header, or an empty string.
+func (c *Client) GetJSON(ctx context.Context, reqUrl string, result any) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", reqUrl, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer CloseBody(resp)
+
+	if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+		return "", fmt.Errorf("failed to decode JSON response of %s: %w", reqUrl, err)
+	}
+
+	next, _ := NextPage(resp)
+	return next, nil
+}
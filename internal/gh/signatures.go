@@ -0,0 +1,107 @@
+package gh
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+// CommitSignature is GitHub's verification of the signature of a commit.
+type CommitSignature struct {
+	// state of the signature, e.g., VALID, UNKNOWN_KEY, or UNSIGNED for commits without signature
+	State string
+	// the commit has been signed by GitHub, e.g., when it was created in the web interface
+	WasSignedByGitHub bool
+	// login of the user the signing key belongs to. Empty if the key is unknown to GitHub.
+	Signer string
+}
+
+// commitSignatureBatchSize is the number of commits queried in a single request.
+const commitSignatureBatchSize = 100
+
+const commitSignatureFragment = `
+fragment commitSignature on Commit {
+	oid
+	signature {
+		state
+		wasSignedByGitHub
+		signer {
+			login
+		}
+	}
+}
+`
+
+type commitSignatureNode struct {
+	Oid       string `json:"oid"`
+	Signature *struct {
+		State             string `json:"state"`
+		WasSignedByGitHub bool   `json:"wasSignedByGitHub"`
+		Signer            *struct {
+			Login string `json:"login"`
+		} `json:"signer"`
+	} `json:"signature"`
+}
+
+type CommitSignatureResponse struct {
+	Data struct {
+		// the commits are queried with the aliases c0, c1, ... and are null if GitHub doesn't know them
+		Repository map[string]*commitSignatureNode `json:"repository"`
+	} `json:"data"`
+}
+
+// commitSignatureQuery returns a query of the signatures of n commits, whose
+// hashes are passed as variables $c0 to $c<n-1>.
+func commitSignatureQuery(n int) string {
+	var b strings.Builder
+	b.WriteString("query ($owner: String!, $name: String!")
+	for i := range n {
+		fmt.Fprintf(&b, ", $c%d: GitObjectID!", i)
+	}
+	b.WriteString(") {\n\trepository(owner: $owner, name: $name) {\n")
+	for i := range n {
+		fmt.Fprintf(&b, "\t\tc%d: object(oid: $c%d) {\n\t\t\t...commitSignature\n\t\t}\n", i, i)
+	}
+	b.WriteString("\t}\n}\n")
+	b.WriteString(commitSignatureFragment)
+	return b.String()
+}
+
+// GetCommitSignatures returns GitHub's verification of the signatures of the commits
+// with the given hashes. The commits are queried in batches. Commits unknown to GitHub
+// are missing in the result.
+func (c *Client) GetCommitSignatures(ctx context.Context, owner, repo string, hashes []string) (map[string]CommitSignature, error) {
+	res := make(map[string]CommitSignature, len(hashes))
+	for start := 0; start < len(hashes); start += commitSignatureBatchSize {
+		batch := hashes[start:min(start+commitSignatureBatchSize, len(hashes))]
+		variables := map[string]any{
+			"owner": owner,
+			"name":  repo,
+		}
+		for i, h := range batch {
+			variables[fmt.Sprintf("c%d", i)] = h
+		}
+
+		var resp CommitSignatureResponse
+		if err := c.executeGraphQLRequest(ctx, commitSignatureQuery(len(batch)), variables, &resp); err != nil {
+			return nil, fmt.Errorf("fetching signatures of commits failed: %w", err)
+		}
+		for _, node := range resp.Data.Repository {
+			if node == nil || node.Oid == "" {
+				continue
+			}
+			s := CommitSignature{State: "UNSIGNED"}
+			if node.Signature != nil {
+				s.State = node.Signature.State
+				s.WasSignedByGitHub = node.Signature.WasSignedByGitHub
+				if node.Signature.Signer != nil {
+					s.Signer = node.Signature.Signer.Login
+				}
+			}
+			res[node.Oid] = s
+		}
+	}
+	slog.Default().Info("Signatures verified by GitHub", "commits", len(hashes), "known", len(res))
+	return res, nil
+}
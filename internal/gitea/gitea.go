@@ -0,0 +1,230 @@
+package gitea
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"project-integrity-calculator/internal/gh"
+)
+
+// Repository is a repository hosted on Gitea or Forgejo. It implements
+// forge.Forge by mapping the pull requests of the REST API to gh.PR.
+type Repository struct {
+	client      *gh.Client
+	apiURL      string
+	owner, name string
+	token       string
+}
+
+// ClientConfig returns the configuration of a gh.Client for the Gitea instance at baseURL.
+// Gitea accepts tokens as bearer tokens, so the GitHub client can be reused.
+func ClientConfig(baseURL, token string, cache *gh.Cache) gh.ClientConfig {
+	return gh.ClientConfig{
+		Token:   token,
+		RestURL: apiURL(baseURL),
+		Cache:   cache,
+	}
+}
+
+// NewRepository creates a Repository for owner/name on the Gitea instance at baseURL.
+func NewRepository(client *gh.Client, baseURL, owner, name, token string) *Repository {
+	return &Repository{
+		client: client,
+		apiURL: apiURL(baseURL),
+		owner:  owner,
+		name:   name,
+		token:  token,
+	}
+}
+
+func apiURL(baseURL string) string {
+	return strings.TrimSuffix(baseURL, "/") + "/api/v1"
+}
+
+type user struct {
+	Login string `json:"login"`
+}
+
+type repositoryResponse struct {
+	CloneUrl      string `json:"clone_url"`
+	DefaultBranch string `json:"default_branch"`
+	Stars         int    `json:"stars_count"`
+}
+
+func (r *Repository) GetRepoInfo(ctx context.Context) (*gh.RepoInfo, error) {
+	slog.Default().Info("Getting repo info from Gitea", "owner", r.owner, "repo", r.name)
+
+	var repo repositoryResponse
+	if _, err := r.client.GetJSON(ctx, r.repoURL(""), &repo); err != nil {
+		return nil, err
+	}
+
+	// Gitea returns the size of each language in bytes
+	var sizes map[string]int64
+	if _, err := r.client.GetJSON(ctx, r.repoURL("/languages"), &sizes); err != nil {
+		return nil, err
+	}
+	languages := make([]string, 0, len(sizes))
+	for l := range sizes {
+		languages = append(languages, l)
+	}
+	slices.SortFunc(languages, func(a, b string) int {
+		if sizes[a] != sizes[b] {
+			return int(sizes[b] - sizes[a])
+		}
+		return strings.Compare(a, b)
+	})
+
+	return &gh.RepoInfo{
+		CloneUrl:      repo.CloneUrl,
+		DefaultBranch: repo.DefaultBranch,
+		Languages:     languages,
+		Stars:         repo.Stars,
+	}, nil
+}
+
+type pullRequest struct {
+	Number         int       `json:"number"`
+	Title          string    `json:"title"`
+	Merged         bool      `json:"merged"`
+	MergedAt       time.Time `json:"merged_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+	MergeCommitSha string    `json:"merge_commit_sha"`
+	MergeBase      string    `json:"merge_base"`
+	User           user      `json:"user"`
+	MergedBy       user      `json:"merged_by"`
+	Base           struct {
+		Ref string `json:"ref"`
+		Sha string `json:"sha"`
+	} `json:"base"`
+	Head struct {
+		Sha string `json:"sha"`
+	} `json:"head"`
+}
+
+type review struct {
+	State string `json:"state"`
+	User  user   `json:"user"`
+}
+
+// Gitea review states mapped to the states used by GitHub
+var reviewStates = map[string]string{
+	"APPROVED":        "APPROVED",
+	"REQUEST_CHANGES": "CHANGES_REQUESTED",
+	"COMMENT":         "COMMENTED",
+	"PENDING":         "PENDING",
+}
+
+// GetPullRequests returns an iterator over all merged pull requests targeting branch.
+// The API doesn't allow to filter by base branch or merge status, so all closed
+// pull requests are fetched and filtered locally. The reviews are fetched with
+// one additional request for each merged pull request.
+// If since is not zero, only pull requests merged after since are returned. They are
+// ordered by their last update, which is never before their merge, to stop paging early.
+func (r *Repository) GetPullRequests(ctx context.Context, branch string, since time.Time) *gh.PRIterator {
+	return gh.NewPRIterator(func(yield func([]gh.PR) bool) error {
+		next := r.repoURL("/pulls?state=closed&limit=50")
+		if !since.IsZero() {
+			next += "&sort=recentupdate"
+		}
+		for next != "" {
+			var page []pullRequest
+			var err error
+			next, err = r.client.GetJSON(ctx, next, &page)
+			if err != nil {
+				return fmt.Errorf("fetching pull requests failed: %w", err)
+			}
+
+			prs := make([]gh.PR, 0, len(page))
+			for _, p := range page {
+				if !p.Merged || p.Base.Ref != branch {
+					continue
+				}
+				pr, err := r.toPR(ctx, p)
+				if err != nil {
+					return err
+				}
+				if !since.IsZero() && !pr.MergedAfter(since) {
+					continue
+				}
+				prs = append(prs, pr)
+			}
+
+			if !yield(prs) {
+				return nil
+			}
+
+			if !since.IsZero() && len(page) > 0 {
+				updatedAt := page[len(page)-1].UpdatedAt
+				if !updatedAt.IsZero() && !updatedAt.After(since) {
+					return nil
+				}
+			}
+		}
+		return nil
+	})
+}
+
+func (r *Repository) toPR(ctx context.Context, p pullRequest) (gh.PR, error) {
+	// the sha of the base is the tip of the base branch when the pull request
+	// was last updated, the merge base is more precise if available
+	base := p.MergeBase
+	if base == "" {
+		base = p.Base.Sha
+	}
+	pr := gh.PR{
+		BaseRefOid:  base,
+		HeadRefOid:  p.Head.Sha,
+		Number:      p.Number,
+		Title:       p.Title,
+		State:       "MERGED",
+		MergeCommit: gh.MergeCommit{Oid: p.MergeCommitSha},
+		MergedAt:    p.MergedAt,
+		Author:      gh.Actor{Login: p.User.Login, Type: "User"},
+		MergedBy:    gh.Actor{Login: p.MergedBy.Login, Type: "User"},
+		HeadRef:     fmt.Sprintf("refs/pull/%d/head", p.Number),
+	}
+
+	next := r.repoURL(fmt.Sprintf("/pulls/%d/reviews?limit=50", p.Number))
+	for next != "" {
+		var reviews []review
+		var err error
+		next, err = r.client.GetJSON(ctx, next, &reviews)
+		if err != nil {
+			return gh.PR{}, fmt.Errorf("fetching reviews of pull request %d failed: %w", p.Number, err)
+		}
+		for _, rev := range reviews {
+			pr.Reviews.Nodes = append(pr.Reviews.Nodes, gh.Review{
+				State:  reviewStates[rev.State],
+				Author: gh.Actor{Login: rev.User.Login, Type: "User"},
+			})
+		}
+	}
+
+	return pr, nil
+}
+
+// GetForcePushInfo always returns zero, as Gitea doesn't expose force pushes in its API.
+func (r *Repository) GetForcePushInfo(ctx context.Context, branch string) (int, error) {
+	slog.Default().Warn("Force pushes can't be queried from Gitea. Reporting zero force pushes.", "branch", branch)
+	return 0, nil
+}
+
+// GitAuth uses the token as user name, which Gitea and Forgejo accept
+// together with the placeholder password x-oauth-basic.
+func (r *Repository) GitAuth(ctx context.Context) (string, error) {
+	if r.token == "" {
+		return "", nil
+	}
+	return "Basic " + base64.StdEncoding.EncodeToString([]byte(r.token+":x-oauth-basic")), nil
+}
+
+func (r *Repository) repoURL(suffix string) string {
+	return r.apiURL + "/repos/" + url.PathEscape(r.owner) + "/" + url.PathEscape(r.name) + suffix
+}
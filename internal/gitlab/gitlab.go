@@ -0,0 +1,103 @@
+package gitlab
+
+import (
+	"context"
+	"encoding/base64"
+	"log/slog"
+	"net/url"
+	"slices"
+	"strings"
+
+	"project-integrity-calculator/internal/gh"
+)
+
+// DefaultURL is the URL of gitlab.com. Self-hosted instances use their own host.
+const DefaultURL = "https://gitlab.com"
+
+// Project is a GitLab project. It implements forge.Forge by mapping
+// merge requests to gh.PR. Approvals are mapped to approving reviews.
+type Project struct {
+	client *gh.Client
+	// path with namespace, e.g., group/subgroup/project
+	path    string
+	restURL string
+	token   string
+}
+
+// NewProject creates a Project for the project at path on the GitLab instance at baseURL.
+// The client must be configured with the GraphQL endpoint of the instance, see ClientConfig.
+func NewProject(client *gh.Client, baseURL, path, token string) *Project {
+	return &Project{
+		client:  client,
+		path:    path,
+		restURL: strings.TrimSuffix(baseURL, "/") + "/api/v4",
+		token:   token,
+	}
+}
+
+// ClientConfig returns the configuration of a gh.Client for the GitLab instance at baseURL.
+// GitLab accepts tokens as bearer tokens and reports rate limits with Retry-After,
+// so the GitHub client can be reused.
+func ClientConfig(baseURL, token string, cache *gh.Cache) gh.ClientConfig {
+	baseURL = strings.TrimSuffix(baseURL, "/")
+	return gh.ClientConfig{
+		Token:      token,
+		GraphQLURL: baseURL + "/api/graphql",
+		RestURL:    baseURL + "/api/v4",
+		Cache:      cache,
+	}
+}
+
+type projectResponse struct {
+	HttpUrlToRepo string `json:"http_url_to_repo"`
+	DefaultBranch string `json:"default_branch"`
+	StarCount     int    `json:"star_count"`
+}
+
+func (p *Project) GetRepoInfo(ctx context.Context) (*gh.RepoInfo, error) {
+	slog.Default().Info("Getting repo info from GitLab", "project", p.path)
+
+	var project projectResponse
+	if _, err := p.client.GetJSON(ctx, p.projectURL(""), &project); err != nil {
+		return nil, err
+	}
+
+	// GitLab returns the share of each language in percent
+	var shares map[string]float64
+	if _, err := p.client.GetJSON(ctx, p.projectURL("/languages"), &shares); err != nil {
+		return nil, err
+	}
+	languages := make([]string, 0, len(shares))
+	for l := range shares {
+		languages = append(languages, l)
+	}
+	slices.SortFunc(languages, func(a, b string) int {
+		if shares[a] > shares[b] {
+			return -1
+		}
+		if shares[a] < shares[b] {
+			return 1
+		}
+		return strings.Compare(a, b)
+	})
+
+	return &gh.RepoInfo{
+		CloneUrl:      project.HttpUrlToRepo,
+		DefaultBranch: project.DefaultBranch,
+		Languages:     languages,
+		Stars:         project.StarCount,
+	}, nil
+}
+
+// GitAuth uses the token as password, as GitLab accepts personal, project,
+// and group access tokens with any user name.
+func (p *Project) GitAuth(ctx context.Context) (string, error) {
+	if p.token == "" {
+		return "", nil
+	}
+	return "Basic " + base64.StdEncoding.EncodeToString([]byte("oauth2:"+p.token)), nil
+}
+
+func (p *Project) projectURL(suffix string) string {
+	return p.restURL + "/projects/" + url.PathEscape(p.path) + suffix
+}
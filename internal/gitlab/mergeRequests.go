@@ -0,0 +1,175 @@
+package gitlab
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"project-integrity-calculator/internal/gh"
+)
+
+const mergeRequestQuery = `
+query ($path: ID!, $branch: String!, $mergedAfter: Time, $after: String) {
+	project(fullPath: $path) {
+		mergeRequests(state: merged, targetBranches: [$branch], mergedAfter: $mergedAfter, first: 100, after: $after) {
+			nodes {
+				iid
+				title
+				mergedAt
+				mergeCommitSha
+				squashCommitSha
+				diffRefs {
+					baseSha
+					headSha
+				}
+				author {
+					username
+					bot
+				}
+				mergeUser {
+					username
+					bot
+				}
+				approvedBy {
+					nodes {
+						username
+						bot
+					}
+				}
+			}
+			pageInfo {
+				hasNextPage
+				startCursor
+				endCursor
+			}
+		}
+	}
+}
+`
+
+type user struct {
+	Username string `json:"username"`
+	Bot      bool   `json:"bot"`
+}
+
+type mergeRequest struct {
+	Iid             string    `json:"iid"`
+	Title           string    `json:"title"`
+	MergedAt        time.Time `json:"mergedAt"`
+	MergeCommitSha  string    `json:"mergeCommitSha"`
+	SquashCommitSha string    `json:"squashCommitSha"`
+	DiffRefs        struct {
+		BaseSha string `json:"baseSha"`
+		HeadSha string `json:"headSha"`
+	} `json:"diffRefs"`
+	Author     user `json:"author"`
+	MergeUser  user `json:"mergeUser"`
+	ApprovedBy struct {
+		Nodes []user `json:"nodes"`
+	} `json:"approvedBy"`
+}
+
+type mergeRequestResponse struct {
+	Data struct {
+		Project struct {
+			MergeRequests struct {
+				Nodes    []mergeRequest `json:"nodes"`
+				PageInfo gh.Pagination  `json:"pageInfo"`
+			} `json:"mergeRequests"`
+		} `json:"project"`
+	} `json:"data"`
+}
+
+// GetPullRequests returns an iterator over all merged merge requests targeting branch.
+// If since is not zero, only merge requests merged after since are returned.
+func (p *Project) GetPullRequests(ctx context.Context, branch string, since time.Time) *gh.PRIterator {
+	return gh.NewPRIterator(func(yield func([]gh.PR) bool) error {
+		variables := map[string]any{
+			"path":   p.path,
+			"branch": branch,
+		}
+		if !since.IsZero() {
+			variables["mergedAfter"] = since.Format(time.RFC3339)
+		}
+
+		for {
+			var resp mergeRequestResponse
+			if err := p.client.Query(ctx, mergeRequestQuery, variables, &resp); err != nil {
+				return fmt.Errorf("fetching merge requests (cursor %v) failed: %w", variables["after"], err)
+			}
+
+			mrs := resp.Data.Project.MergeRequests
+			prs := make([]gh.PR, 0, len(mrs.Nodes))
+			for _, mr := range mrs.Nodes {
+				pr, err := toPR(mr)
+				if err != nil {
+					slog.Default().Warn("Skipping merge request", "iid", mr.Iid, "err", err)
+					continue
+				}
+				// mergedAfter includes merge requests merged at since
+				if !since.IsZero() && !pr.MergedAfter(since) {
+					continue
+				}
+				prs = append(prs, pr)
+			}
+
+			if !yield(prs) {
+				return nil
+			}
+
+			if !mrs.PageInfo.HasNextPage {
+				return nil
+			}
+			variables["after"] = mrs.PageInfo.EndCursor
+		}
+	})
+}
+
+// toPR maps a merge request to the PR model of GitHub. Approvals become
+// approving reviews, as GitLab has no other review states.
+func toPR(mr mergeRequest) (gh.PR, error) {
+	number, err := strconv.Atoi(mr.Iid)
+	if err != nil {
+		return gh.PR{}, err
+	}
+
+	// squashed merge requests don't contain the original commits in the target branch,
+	// but a squash commit and, depending on the merge method, a merge commit
+	mergeCommit := mr.MergeCommitSha
+	squashCommit := mr.SquashCommitSha
+	if mergeCommit == "" {
+		mergeCommit = squashCommit
+		squashCommit = ""
+	}
+
+	pr := gh.PR{
+		BaseRefOid:  mr.DiffRefs.BaseSha,
+		HeadRefOid:  mr.DiffRefs.HeadSha,
+		Number:      number,
+		Title:       mr.Title,
+		State:       "MERGED",
+		MergeCommit: gh.MergeCommit{Oid: mergeCommit},
+		MergedAt:    mr.MergedAt,
+		Author:      toActor(mr.Author),
+		MergedBy:    toActor(mr.MergeUser),
+		HeadRef:     fmt.Sprintf("refs/merge-requests/%d/head", number),
+	}
+	pr.SquashCommitOid = squashCommit
+	for _, u := range mr.ApprovedBy.Nodes {
+		pr.Reviews.Nodes = append(pr.Reviews.Nodes, gh.Review{
+			State:  "APPROVED",
+			Author: toActor(u),
+		})
+	}
+	return pr, nil
+}
+
+func toActor(u user) gh.Actor {
+	t := "User"
+	if u.Bot {
+		t = "Bot"
+	}
+	return gh.Actor{Login: u.Username, Type: t}
+}
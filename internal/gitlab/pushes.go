@@ -0,0 +1,87 @@
+package gitlab
+
+import (
+	"context"
+	"log/slog"
+	"net/url"
+)
+
+type pushEvent struct {
+	PushData struct {
+		Action     string `json:"action"`
+		RefType    string `json:"ref_type"`
+		Ref        string `json:"ref"`
+		CommitFrom string `json:"commit_from"`
+		CommitTo   string `json:"commit_to"`
+	} `json:"push_data"`
+}
+
+type mergeBaseResponse struct {
+	Id string `json:"id"`
+}
+
+// MaxForcePushChecks limits the number of pushes checked for being a force push,
+// each of which requires a request. Older pushes beyond this limit are ignored.
+const MaxForcePushChecks = 1000
+
+// GetForcePushInfo counts the force pushes to branch. GitLab doesn't mark force
+// pushes in its push events, so each push to the branch is checked using the
+// merge base API. A push is a force push if the previous head of the branch is
+// not an ancestor of the new head. Events are returned newest first, so at most
+// the MaxForcePushChecks latest pushes are checked.
+func (p *Project) GetForcePushInfo(ctx context.Context, branch string) (int, error) {
+	slog.Default().Info("Getting push events from GitLab", "project", p.path, "branch", branch)
+
+	numberForcePush := 0
+	checks := 0
+	next := p.projectURL("/events?action=pushed&per_page=100")
+	for next != "" {
+		var page []pushEvent
+		var err error
+		next, err = p.client.GetJSON(ctx, next, &page)
+		if err != nil {
+			slog.Default().Error("Getting push events failed", "error", err)
+			return 0, err
+		}
+
+		for _, e := range page {
+			d := e.PushData
+			if d.Action != "pushed" || d.RefType != "branch" || d.Ref != branch || d.CommitFrom == "" || d.CommitTo == "" {
+				continue
+			}
+			if checks >= MaxForcePushChecks {
+				slog.Default().Warn("Reached max number of checked pushes. Older pushes are ignored.", "pushes", checks, "force pushes", numberForcePush)
+				return numberForcePush, nil
+			}
+			checks++
+
+			forced, err := p.isForcePush(ctx, d.CommitFrom, d.CommitTo)
+			if err != nil {
+				if ctx.Err() != nil {
+					return 0, ctx.Err()
+				}
+				slog.Default().Warn("Checking pushed commits failed. Push is ignored.", "from", d.CommitFrom, "to", d.CommitTo, "error", err)
+				continue
+			}
+			if forced {
+				numberForcePush++
+			}
+		}
+	}
+
+	return numberForcePush, nil
+}
+
+// isForcePush returns whether the push from the head from to the head to rewrote the
+// branch, i.e., from is not an ancestor of to.
+func (p *Project) isForcePush(ctx context.Context, from, to string) (bool, error) {
+	query := url.Values{}
+	query.Add("refs[]", from)
+	query.Add("refs[]", to)
+
+	var base mergeBaseResponse
+	if _, err := p.client.GetJSON(ctx, p.projectURL("/repository/merge_base?"+query.Encode()), &base); err != nil {
+		return false, err
+	}
+	return base.Id != from, nil
+}
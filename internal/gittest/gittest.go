@@ -0,0 +1,97 @@
+// Package gittest creates git repositories for tests.
+package gittest
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Repo is a repository in a temporary directory, whose commits are made by
+// the same author. The config of the user and the system is ignored.
+type Repo struct {
+	t   testing.TB
+	Dir string
+	// date of the commits made afterwards, the current time if empty
+	date string
+}
+
+// New initializes a repository with the branch main. The test is skipped if git is not installed.
+func New(t testing.TB) *Repo {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	r := &Repo{t: t, Dir: t.TempDir()}
+	r.Git("init", "-q", "-b", "main")
+	return r
+}
+
+// Run runs git in the repository with stdin as input and returns its output.
+// The test fails if git fails.
+func (r *Repo) Run(stdin string, args ...string) string {
+	r.t.Helper()
+	out, stderr, err := r.run(stdin, args...)
+	if err != nil {
+		r.t.Fatalf("git %v failed: %s: %s", args, err, stderr)
+	}
+	return out
+}
+
+// Git runs git in the repository and returns its output. The test fails if git fails.
+func (r *Repo) Git(args ...string) string {
+	r.t.Helper()
+	return r.Run("", args...)
+}
+
+// TryGit runs git in the repository and returns its error, e.g., for merges with conflicts.
+func (r *Repo) TryGit(args ...string) error {
+	_, _, err := r.run("", args...)
+	return err
+}
+
+func (r *Repo) run(stdin string, args ...string) (string, string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = r.Dir
+	cmd.Stdin = strings.NewReader(stdin)
+	cmd.Env = append(os.Environ(),
+		"GIT_AUTHOR_NAME=Test", "GIT_AUTHOR_EMAIL=test@example.com",
+		"GIT_COMMITTER_NAME=Test", "GIT_COMMITTER_EMAIL=test@example.com",
+		"GIT_CONFIG_NOSYSTEM=1", "GIT_CONFIG_GLOBAL=/dev/null",
+	)
+	if r.date != "" {
+		cmd.Env = append(cmd.Env, "GIT_AUTHOR_DATE="+r.date, "GIT_COMMITTER_DATE="+r.date)
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	return string(out), stderr.String(), err
+}
+
+// SetDate sets the author and committer date of the commits made afterwards,
+// e.g., 2020-01-01T12:00:00Z.
+func (r *Repo) SetDate(date string) {
+	r.date = date
+}
+
+// WriteFile writes content to the file name relative to the root of the repository.
+func (r *Repo) WriteFile(name, content string) {
+	r.t.Helper()
+	p := filepath.Join(r.Dir, name)
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		r.t.Fatal(err)
+	}
+	if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+		r.t.Fatal(err)
+	}
+}
+
+// Commit commits all changes of the working tree with the message, even if there are none.
+func (r *Repo) Commit(msg string) {
+	r.t.Helper()
+	r.Git("add", "-A")
+	r.Git("commit", "-q", "--allow-empty", "--allow-empty-message", "-m", msg)
+}
@@ -3,6 +3,7 @@ package io
 import (
 	"encoding/json"
 	"os"
+	"time"
 )
 
 type Result struct {
@@ -10,27 +11,95 @@ type Result struct {
 }
 
 type Repo struct {
-	Branch            string
-	Head              string
-	Url               string
+	Branch string
+	Head   string
+	// merge time of the newest processed PR. Together with Head it is the
+	// checkpoint from which later analyses continue incrementally.
+	LastMergedAt time.Time `json:",omitzero"`
+	Url          string
+	// Incomplete is set if some PRs couldn't be processed, in which case
+	// their commits are falsely reported in CommitsWithoutPR, if not all
+	// reviews of some PRs could be fetched, so their review status may be wrong,
+	// or if the force pushes couldn't be fetched, so NumberForcePushes is too low
+	Incomplete        bool
 	NumberForcePushes int
+	Score             Score
 	Stats             Stats
 	CommitsWithoutPR  []Commit
-	UnsignedCommits   []Commit
+	// commits without a valid signature, i.e., with the verdict missing, invalid, or revoked.
+	// With a SignaturePolicy only the commits not violating it, which are informational.
+	UnsignedCommits []Commit
+	// policy which commits have to be signed. Nil if all commits have to be signed.
+	SignaturePolicy *SignaturePolicy `json:",omitempty"`
+	// commits violating the SignaturePolicy
+	SignaturePolicyViolations []SignatureViolation `json:",omitempty"`
+	// commits which reached the branch only through PRs without an approving review
+	CommitsWithUnreviewedPR []Commit
+	// PRs which have been merged or approved by their own author
+	SelfMergedPRs []PullRequest
+	// commits whose signatures are verified differently by git and the forge
+	SignatureDisagreements []SignatureDisagreement `json:",omitempty"`
 }
 
 type Stats struct {
 	NumberCommits int
 	NumberPRs     int
-	Languages     []string
-	Stars         int
+	Reviews       ReviewStats
+	// number of commits by the verdict of their signature
+	Signatures map[Verdict]int `json:",omitempty"`
+	Languages  []string
+	Stars      int
+}
+
+// ReviewStats counts the merged PRs by the outcome of their reviews.
+type ReviewStats struct {
+	Approved         int
+	CommentedOnly    int
+	ChangesRequested int // changes were requested, but the PR was merged anyway
+	Unreviewed       int
+}
+
+// Score is the Code Integrity Score of a repository. Value is the weighted
+// mean of all SubScores and lies between 0 (worst) and 1 (best).
+type Score struct {
+	Value     float64
+	SubScores []SubScore
+}
+
+type SubScore struct {
+	Name   string
+	Value  float64
+	Weight float64
+}
+
+type PullRequest struct {
+	Number   int
+	Title    string
+	Author   string
+	MergedBy string
+	MergedAt time.Time
+	// reasons why the PR has been flagged, e.g., AUTHOR_MERGED or SELF_APPROVED
+	Reasons []string
 }
 
 type Commit struct {
-	GitOID  string
+	GitOID string
+	// hashes of the parent commits. Merge commits have more than one parent.
+	Parents []string
+	// who wrote the change and who created the commit, e.g., by applying or rebasing it
+	Author    Person
+	Committer Person
+	// first line of the message
+	Subject string
+	// full message of the commit as stored in the repository
 	Message string
-	Date    string
-	// show "G" for a good (valid) signature, "B" for a bad signature,
+	// trailers at the end of the message, e.g., Signed-off-by, Co-authored-by, or Reviewed-by
+	Trailers []Trailer `json:",omitempty"`
+	// commit date in the time zone of the committer. Like all timestamps of the
+	// results, it is serialized in RFC 3339 format, e.g., 2024-01-31T12:00:00+01:00.
+	Date time.Time
+	// result of the verification by git, on which Verdict is based:
+	// "G" for a good (valid) signature, "B" for a bad signature,
 	// "U" for a good signature with unknown validity,
 	// "X" for a good signature that has expired,
 	// "Y" for a good signature made by an expired key,
@@ -38,6 +107,114 @@ type Commit struct {
 	// "E" if the signature cannot be checked (e.g. missing key)
 	// and "N" for no signature
 	Signed string
+	// verdict of the signature. Empty in results of earlier versions.
+	Verdict Verdict `json:",omitempty"`
+	// key used to sign the commit, i.e., the key id of a GPG key or the fingerprint of an SSH key
+	SigningKey string `json:",omitempty"`
+	// name of the signer as reported by GPG, or the principal of an SSH key
+	Signer string `json:",omitempty"`
+	// verification of the signature by the forge, if requested
+	ForgeSignature *ForgeSignature `json:",omitempty"`
+}
+
+// SignaturePolicy defines which commits have to be signed.
+type SignaturePolicy struct {
+	// signatures are required from the date, tag, or commit hash onward.
+	// They are required for all commits if empty.
+	Since string `json:",omitempty"`
+	// commits changing files matching the glob patterns, e.g., .github/workflows/** or go.mod,
+	// require a trusted signature
+	Paths []string `json:",omitempty"`
+}
+
+// SignatureViolation is a commit violating the SignaturePolicy.
+type SignatureViolation struct {
+	Commit Commit
+	// reasons why the commit violates the policy, e.g., SIGNATURE_REQUIRED or TRUSTED_SIGNATURE_REQUIRED
+	Reasons []string
+}
+
+// ForgeSignature is the verification of a commit signature by the forge.
+type ForgeSignature struct {
+	// state reported by the forge, e.g., VALID, UNKNOWN_KEY, or UNSIGNED
+	State string
+	// the commit has been signed by the forge, e.g., when it was created in the web interface
+	SignedByForge bool
+	// account the signing key belongs to
+	Signer string `json:",omitempty"`
+}
+
+// SignatureDisagreement is a commit whose signature is valid according to either git or the forge, but not both.
+type SignatureDisagreement struct {
+	GitOID string
+	// verdict of the verification by git
+	Local Verdict
+	// state reported by the forge
+	Forge string
+}
+
+// Verdict is the result of the verification of a commit signature
+// with the trusted keys and the known keys of the contributors.
+type Verdict string
+
+const (
+	// valid signature made by a trusted key
+	VerdictTrusted Verdict = "trusted"
+	// valid signature made by a known key of a contributor, which isn't trusted
+	VerdictUntrusted Verdict = "untrusted-but-valid"
+	// valid signature made by an expired key or expired signature
+	VerdictExpired Verdict = "expired"
+	// valid signature made by a revoked key
+	VerdictRevoked Verdict = "revoked"
+	// signature made by an unknown key, which can't be attributed to a contributor.
+	// Such commits are signed, but aren't reported as unsigned commits.
+	VerdictUnverifiable Verdict = "unverifiable"
+	// bad signature
+	VerdictInvalid Verdict = "invalid"
+	// no signature
+	VerdictMissing Verdict = "missing"
+)
+
+// Person identifies the author or committer of a commit.
+type Person struct {
+	Name  string
+	Email string
+	Date  time.Time
+}
+
+// Trailer is a "Key: Value" line at the end of a commit message.
+type Trailer struct {
+	Key   string
+	Value string
+}
+
+// legacyDateLayout is the format of commit dates in results of earlier versions.
+const legacyDateLayout = "2006-01-02 15:04:05 -0700"
+
+// UnmarshalJSON decodes commits with dates in RFC 3339 format and in the format of earlier versions.
+func (c *Commit) UnmarshalJSON(data []byte) error {
+	type commit Commit
+	aux := struct {
+		*commit
+		Date string
+	}{commit: (*commit)(c)}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	if aux.Date == "" {
+		c.Date = time.Time{}
+		return nil
+	}
+
+	date, err := time.Parse(time.RFC3339, aux.Date)
+	if err != nil {
+		date, err = time.Parse(legacyDateLayout, aux.Date)
+		if err != nil {
+			return err
+		}
+	}
+	c.Date = date
+	return nil
 }
 
 func GetResult(in string) (*Repo, error) {
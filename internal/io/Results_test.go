@@ -0,0 +1,65 @@
+package io
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestCommitUnmarshalJSON(t *testing.T) {
+	date := time.Date(2024, 3, 1, 12, 30, 0, 0, time.FixedZone("", 2*60*60))
+	tests := []struct {
+		name    string
+		json    string
+		want    time.Time
+		wantErr bool
+	}{
+		{"RFC 3339", `{"GitOID": "abc", "Date": "2024-03-01T12:30:00+02:00"}`, date, false},
+		{"legacy format", `{"GitOID": "abc", "Date": "2024-03-01 12:30:00 +0200"}`, date, false},
+		{"empty date", `{"GitOID": "abc", "Date": ""}`, time.Time{}, false},
+		{"missing date", `{"GitOID": "abc"}`, time.Time{}, false},
+		{"invalid date", `{"GitOID": "abc", "Date": "yesterday"}`, time.Time{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var c Commit
+			err := json.Unmarshal([]byte(tt.json), &c)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Unmarshal() = %v, want error", c.Date)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !c.Date.Equal(tt.want) {
+				t.Errorf("Date = %v, want %v", c.Date, tt.want)
+			}
+			if c.GitOID != "abc" {
+				t.Errorf("GitOID = %q, want abc", c.GitOID)
+			}
+		})
+	}
+}
+
+func TestCommitRoundTrip(t *testing.T) {
+	want := Commit{
+		GitOID:   "abc",
+		Subject:  "subject",
+		Date:     time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC),
+		Trailers: []Trailer{{Key: "Signed-off-by", Value: "Alice <alice@example.com>"}},
+		Verdict:  VerdictTrusted,
+	}
+	data, err := json.Marshal(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got Commit
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if !got.Date.Equal(want.Date) || got.GitOID != want.GitOID || got.Subject != want.Subject || got.Verdict != want.Verdict || len(got.Trailers) != 1 || got.Trailers[0] != want.Trailers[0] {
+		t.Errorf("Unmarshal(Marshal(%+v)) = %+v", want, got)
+	}
+}
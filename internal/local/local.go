@@ -0,0 +1,161 @@
+package local
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"time"
+
+	"project-integrity-calculator/internal/gh"
+	"project-integrity-calculator/internal/vcs"
+)
+
+// Repository is a local git repository together with a manifest of its PRs.
+// It implements forge.Forge without accessing the network, e.g., to analyze
+// mirrors in air-gapped environments or archived data.
+type Repository struct {
+	path     string
+	manifest string
+}
+
+// NewRepository creates a Repository for the git repository at path.
+// The manifest is a JSON array or a JSON Lines file of PRs in the shape of gh.PR.
+func NewRepository(path, manifest string) *Repository {
+	return &Repository{
+		path:     path,
+		manifest: manifest,
+	}
+}
+
+func (r *Repository) GetRepoInfo(ctx context.Context) (*gh.RepoInfo, error) {
+	branch, err := vcs.GetDefaultBranch(ctx, r.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get default branch of %s: %w", r.path, err)
+	}
+	return &gh.RepoInfo{
+		CloneUrl:      r.path,
+		DefaultBranch: branch,
+		Languages:     []string{},
+	}, nil
+}
+
+// number of PRs passed to a worker at once, like a page of the GitHub API
+const pageSize = 100
+
+// GetPullRequests returns an iterator over all merged PRs of the manifest. The manifest
+// doesn't contain the target branch of the PRs, so it must only contain PRs targeting branch.
+// The commits of the PRs must be present in the repository, as nothing is fetched.
+// If since is not zero, only PRs merged after since are returned.
+func (r *Repository) GetPullRequests(ctx context.Context, branch string, since time.Time) *gh.PRIterator {
+	return gh.NewPRIterator(func(yield func([]gh.PR) bool) error {
+		prs, err := readManifest(r.manifest)
+		if err != nil {
+			return err
+		}
+		slog.Default().Info("Read PR manifest", "file", r.manifest, "number of PRs", len(prs))
+
+		page := make([]gh.PR, 0, pageSize)
+		for _, pr := range prs {
+			if pr.State != "" && pr.State != "MERGED" {
+				continue
+			}
+			if !since.IsZero() && !pr.MergedAfter(since) {
+				continue
+			}
+			pr.State = "MERGED"
+			// there is no remote to fetch the refs from
+			pr.HeadRef = ""
+			for i := range pr.Revisions {
+				pr.Revisions[i].HeadRef = ""
+			}
+			page = append(page, pr)
+
+			if len(page) == pageSize {
+				if !yield(page) {
+					return nil
+				}
+				page = make([]gh.PR, 0, pageSize)
+			}
+		}
+		if len(page) > 0 {
+			yield(page)
+		}
+		return nil
+	})
+}
+
+// readManifest reads a JSON array of PRs or one PR per line.
+func readManifest(path string) ([]gh.PR, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := file.Close(); err != nil {
+			slog.Default().Warn("Failed to close manifest", "file", path, "error", err)
+		}
+	}()
+
+	reader := bufio.NewReader(file)
+	var prs []gh.PR
+	first, err := peekNonSpace(reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest %s: %w", path, err)
+	}
+
+	decoder := json.NewDecoder(reader)
+	if first == '[' {
+		if err := decoder.Decode(&prs); err != nil {
+			return nil, fmt.Errorf("failed to decode manifest %s: %w", path, err)
+		}
+		return prs, nil
+	}
+
+	for {
+		var pr gh.PR
+		err := decoder.Decode(&pr)
+		if errors.Is(err, io.EOF) {
+			return prs, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode PR %d of manifest %s: %w", len(prs)+1, path, err)
+		}
+		prs = append(prs, pr)
+	}
+}
+
+// peekNonSpace returns the first byte which isn't white space without consuming it.
+func peekNonSpace(reader *bufio.Reader) (byte, error) {
+	for {
+		b, err := reader.Peek(1)
+		if errors.Is(err, io.EOF) {
+			return 0, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		if !bytes.ContainsAny(b, " \t\r\n") {
+			return b[0], nil
+		}
+		if _, err := reader.Discard(1); err != nil {
+			return 0, err
+		}
+	}
+}
+
+// GetForcePushInfo always returns zero, as force pushes can't be detected locally.
+func (r *Repository) GetForcePushInfo(ctx context.Context, branch string) (int, error) {
+	slog.Default().Warn("Force pushes can't be detected in local mode. Reporting zero force pushes.", "branch", branch)
+	return 0, nil
+}
+
+// GitAuth is always empty, as the repository is never accessed remotely.
+func (r *Repository) GitAuth(ctx context.Context) (string, error) {
+	return "", nil
+}
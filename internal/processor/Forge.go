@@ -0,0 +1,130 @@
+package processor
+
+import (
+	"errors"
+	"fmt"
+	"project-integrity-calculator/internal/forge"
+	"project-integrity-calculator/internal/gerrit"
+	"project-integrity-calculator/internal/gh"
+	"project-integrity-calculator/internal/gitea"
+	"project-integrity-calculator/internal/gitlab"
+	"project-integrity-calculator/internal/local"
+	"strings"
+)
+
+// SplitOwnerAndRepo splits nameWithOwner at the last slash. The owner may contain
+// further slashes, e.g., for projects in GitLab subgroups. Gerrit projects and
+// local repositories don't require an owner, e.g., for top level projects.
+func SplitOwnerAndRepo(kind forge.Kind, nameWithOwner string) (string, string, error) {
+	i := strings.LastIndex(nameWithOwner, "/")
+	if (kind == forge.Gerrit || kind == forge.Local) && i == -1 && nameWithOwner != "" {
+		return "", nameWithOwner, nil
+	}
+	if i <= 0 || i == len(nameWithOwner)-1 {
+		return "", "", errors.New("expected format owner/repo, got " + nameWithOwner)
+	}
+	return nameWithOwner[:i], nameWithOwner[i+1:], nil
+}
+
+// NewClient creates the API client of the forge described by config. A client can be
+// shared between repositories of the forge, so they share its rate limit and its
+// authentication. There is no client for local repositories.
+func NewClient(config RepoConfig) (*gh.Client, error) {
+	var cache *gh.Cache
+	if config.CacheDir != "" {
+		cache = &gh.Cache{
+			Dir:    config.CacheDir,
+			Mode:   config.CacheMode,
+			MaxAge: config.CacheMaxAge,
+		}
+	}
+
+	switch config.Forge {
+	case forge.GitHub, "":
+		clientConfig := gh.ClientConfig{
+			Token:      config.Token,
+			GraphQLURL: config.GraphQLURL,
+			RestURL:    config.RestURL,
+			Cache:      cache,
+		}
+		if config.AppID != "" {
+			clientConfig.App = &gh.AppConfig{
+				AppID:          config.AppID,
+				KeyPath:        config.AppKeyPath,
+				InstallationID: config.InstallationID,
+				Owner:          config.Owner,
+				Repo:           config.Repo,
+			}
+		}
+		return gh.NewClient(clientConfig)
+
+	case forge.GitLab:
+		return gh.NewClient(gitlab.ClientConfig(gitlabURL(config), config.Token, cache))
+
+	case forge.Gitea:
+		// there is no public default instance of Gitea or Forgejo
+		if config.ForgeURL == "" {
+			return nil, errors.New("forge URL is required for Gitea")
+		}
+		return gh.NewClient(gitea.ClientConfig(config.ForgeURL, config.Token, cache))
+
+	case forge.Gerrit:
+		if config.ForgeURL == "" {
+			return nil, errors.New("forge URL is required for Gerrit")
+		}
+		return gh.NewClient(gerrit.ClientConfig(config.Token, cache))
+
+	case forge.Local:
+		return nil, nil
+
+	default:
+		return nil, fmt.Errorf("unknown forge %q", config.Forge)
+	}
+}
+
+func gitlabURL(config RepoConfig) string {
+	if config.ForgeURL == "" {
+		return gitlab.DefaultURL
+	}
+	return config.ForgeURL
+}
+
+// newForge creates the forge.Forge hosting the repository described by config.
+// It uses config.Client if set and creates a client otherwise.
+func newForge(config RepoConfig) (forge.Forge, error) {
+	client := config.Client
+	if client == nil {
+		var err error
+		client, err = NewClient(config)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	switch config.Forge {
+	case forge.GitHub, "":
+		return client.Repository(config.Owner, config.Repo), nil
+
+	case forge.GitLab:
+		return gitlab.NewProject(client, gitlabURL(config), config.Owner+"/"+config.Repo, config.Token), nil
+
+	case forge.Gitea:
+		return gitea.NewRepository(client, config.ForgeURL, config.Owner, config.Repo, config.Token), nil
+
+	case forge.Gerrit:
+		name := config.Repo
+		if config.Owner != "" {
+			name = config.Owner + "/" + config.Repo
+		}
+		return gerrit.NewProject(client, config.ForgeURL, name, config.Token, config.AllPatchSets), nil
+
+	case forge.Local:
+		if config.RepoPath == "" || config.Manifest == "" {
+			return nil, errors.New("repo path and manifest are required for local repositories")
+		}
+		return local.NewRepository(config.RepoPath, config.Manifest), nil
+
+	default:
+		return nil, fmt.Errorf("unknown forge %q", config.Forge)
+	}
+}
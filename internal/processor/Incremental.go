@@ -0,0 +1,102 @@
+package processor
+
+import (
+	"context"
+	"log/slog"
+	"project-integrity-calculator/internal/io"
+	"project-integrity-calculator/internal/vcs"
+	"reflect"
+	"time"
+)
+
+// continueFrom checks whether the analysis can continue from the previous result.
+// It returns nil if the whole branch must be analyzed, e.g., because the history
+// has been rewritten since the previous analysis, the signature policy changed, or the
+// previous result is incomplete. Otherwise it returns the previous result and the merge
+// time after which PRs have to be processed.
+func continueFrom(ctx context.Context, previous *io.Repo, policy *io.SignaturePolicy, dir, branch, head string) (*io.Repo, time.Time) {
+	if previous == nil {
+		return nil, time.Time{}
+	}
+	logger := slog.Default()
+
+	if previous.Branch != branch {
+		logger.Warn("Previous result is for another branch. Analyzing the whole branch.", "previous", previous.Branch, "branch", branch)
+		return nil, time.Time{}
+	}
+	// the findings of the previous result depend on the policy
+	if !reflect.DeepEqual(previous.SignaturePolicy, policy) {
+		logger.Warn("Signature policy of previous result differs. Analyzing the whole branch.", "previous", previous.SignaturePolicy, "policy", policy)
+		return nil, time.Time{}
+	}
+	// PRs missing in the previous result are never processed again, as they were merged before its LastMergedAt
+	if previous.Incomplete {
+		logger.Warn("Previous result is incomplete. Analyzing the whole branch.")
+		return nil, time.Time{}
+	}
+	if previous.Head == "" || head == "" || !vcs.IsAncestor(ctx, dir, previous.Head, head) {
+		logger.Warn("Head of previous result is not part of the branch. Analyzing the whole branch.", "previous head", previous.Head, "head", head)
+		return nil, time.Time{}
+	}
+
+	// without PRs in the previous result all PRs are processed again
+	if previous.Stats.NumberPRs == 0 {
+		return previous, time.Time{}
+	}
+	if previous.LastMergedAt.IsZero() {
+		logger.Warn("Previous result has no merge time of its newest PR. Analyzing the whole branch.")
+		return nil, time.Time{}
+	}
+	return previous, previous.LastMergedAt
+}
+
+// seedPrevious adds the commits without PR and the commits of PRs without approval of the previous
+// result, so they are resolved if they are part of a (reviewed) PR merged since then.
+func seedPrevious(ctx context.Context, previous *io.Repo, dir string, cache *vcs.PatchIdCache, patchIdToCommit, unreviewed map[string]*io.Commit) error {
+	seed := func(commits []io.Commit, m map[string]*io.Commit) error {
+		hashes := make([]string, len(commits))
+		for i, c := range commits {
+			hashes[i] = c.GitOID
+		}
+		patchIds, err := cache.GetOrCreatePatchIds(ctx, dir, hashes)
+		if err != nil {
+			return err
+		}
+		for i := range commits {
+			c := &commits[i]
+			pi := patchIds[c.GitOID]
+			if pi == "" {
+				pi = c.GitOID
+			}
+			m[pi] = c
+		}
+		return nil
+	}
+	if err := seed(previous.CommitsWithoutPR, patchIdToCommit); err != nil {
+		return err
+	}
+	return seed(previous.CommitsWithUnreviewedPR, unreviewed)
+}
+
+// mergePrevious adds the findings and statistics of the previous result to repo.
+// Commits without PR and commits of PRs without approval have been carried over by seedPrevious.
+func mergePrevious(repo *io.Repo, previous *io.Repo) {
+	repo.UnsignedCommits = append(previous.UnsignedCommits, repo.UnsignedCommits...)
+	repo.SelfMergedPRs = append(previous.SelfMergedPRs, repo.SelfMergedPRs...)
+	repo.SignaturePolicyViolations = append(previous.SignaturePolicyViolations, repo.SignaturePolicyViolations...)
+	repo.SignatureDisagreements = append(previous.SignatureDisagreements, repo.SignatureDisagreements...)
+	repo.Stats.NumberPRs += previous.Stats.NumberPRs
+	repo.Stats.Reviews.Approved += previous.Stats.Reviews.Approved
+	repo.Stats.Reviews.CommentedOnly += previous.Stats.Reviews.CommentedOnly
+	repo.Stats.Reviews.ChangesRequested += previous.Stats.Reviews.ChangesRequested
+	repo.Stats.Reviews.Unreviewed += previous.Stats.Reviews.Unreviewed
+	for v, n := range previous.Stats.Signatures {
+		if repo.Stats.Signatures == nil {
+			repo.Stats.Signatures = make(map[io.Verdict]int)
+		}
+		repo.Stats.Signatures[v] += n
+	}
+	if repo.LastMergedAt.IsZero() {
+		repo.LastMergedAt = previous.LastMergedAt
+	}
+}
@@ -0,0 +1,91 @@
+package processor
+
+import (
+	"context"
+	"log/slog"
+	"project-integrity-calculator/internal/io"
+	"project-integrity-calculator/internal/vcs"
+	"time"
+
+	"github.com/hashicorp/go-set/v3"
+)
+
+const (
+	// the commit has to be signed, but has no valid signature
+	SignatureRequired = "SIGNATURE_REQUIRED"
+	// the commit changes a sensitive path, but has no trusted signature
+	TrustedSignatureRequired = "TRUSTED_SIGNATURE_REQUIRED"
+)
+
+// applySignaturePolicy returns the commits violating the policy and the unsigned
+// commits which don't violate it. The commits of the branch before exclude are omitted.
+func applySignaturePolicy(ctx context.Context, policy io.SignaturePolicy, dir, branch, exclude string, commits, unsigned []io.Commit) ([]io.SignatureViolation, []io.Commit, error) {
+	required, err := requiresSignature(ctx, policy.Since, dir, branch)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sensitive := set.New[string](0)
+	if len(policy.Paths) > 0 {
+		sensitive, err = vcs.GetCommitsChangingPaths(ctx, dir, branch, exclude, policy.Paths)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	violations := []io.SignatureViolation{}
+	violating := set.New[string](0)
+	for _, c := range commits {
+		if !required(c) {
+			continue
+		}
+		var reasons []string
+		switch c.Verdict {
+		case io.VerdictMissing, io.VerdictInvalid, io.VerdictRevoked:
+			reasons = append(reasons, SignatureRequired)
+		}
+		if sensitive.Contains(c.GitOID) && c.Verdict != io.VerdictTrusted {
+			reasons = append(reasons, TrustedSignatureRequired)
+		}
+		if len(reasons) > 0 {
+			violations = append(violations, io.SignatureViolation{Commit: c, Reasons: reasons})
+			violating.Insert(c.GitOID)
+		}
+	}
+
+	informational := make([]io.Commit, 0, len(unsigned))
+	for _, c := range unsigned {
+		if !violating.Contains(c.GitOID) {
+			informational = append(informational, c)
+		}
+	}
+	slog.Default().Info("Number signature policy violations", "number", len(violations), "informational unsigned commits", len(informational))
+
+	return violations, informational, nil
+}
+
+// requiresSignature returns whether a commit has to be signed according to since, which
+// is a date in RFC 3339 format or YYYY-MM-DD, a tag, or a commit hash. Signatures are
+// required for commits committed at or after the date and for commits merged after the
+// tagged commit or the commit, including the commit itself. All commits require a
+// signature if since is empty.
+func requiresSignature(ctx context.Context, since, dir, branch string) (func(io.Commit) bool, error) {
+	if since == "" {
+		return func(io.Commit) bool { return true }, nil
+	}
+	for _, layout := range []string{time.RFC3339, time.DateOnly} {
+		if date, err := time.Parse(layout, since); err == nil {
+			return func(c io.Commit) bool { return !c.Date.Before(date) }, nil
+		}
+	}
+
+	commit, err := vcs.ResolveCommit(ctx, dir, since)
+	if err != nil {
+		return nil, err
+	}
+	after, err := vcs.GetCommitsAfter(ctx, dir, branch, commit)
+	if err != nil {
+		return nil, err
+	}
+	return func(c io.Commit) bool { return after.Contains(c.GitOID) }, nil
+}
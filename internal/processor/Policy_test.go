@@ -0,0 +1,223 @@
+package processor
+
+import (
+	"context"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"project-integrity-calculator/internal/gittest"
+	"project-integrity-calculator/internal/io"
+	"project-integrity-calculator/internal/vcs"
+)
+
+// policyFixtureRepo creates a repository whose branch main has the following commits,
+// each committed at the given date:
+//
+//	root          2020-01-01
+//	side          2020-01-15 on a side branch, changes .github/workflows/release/deploy.yml
+//	tagged        2020-02-01 tagged with v1
+//	workflow      2020-03-01 changes .github/workflows/ci.yml
+//	merge side    2020-04-01 merges the side branch
+//	nested go.mod 2020-05-01 adds tools/go.mod
+func policyFixtureRepo(t *testing.T) string {
+	t.Helper()
+	r := gittest.New(t)
+	commit := func(date, msg string, files ...string) {
+		t.Helper()
+		r.SetDate(date + "T12:00:00Z")
+		for _, f := range files {
+			r.WriteFile(f, msg+"\n")
+		}
+		r.Commit(msg)
+	}
+
+	commit("2020-01-01", "root", "README.md")
+	r.Git("checkout", "-q", "-b", "side")
+	commit("2020-01-15", "side", ".github/workflows/release/deploy.yml")
+	r.Git("checkout", "-q", "main")
+	commit("2020-02-01", "tagged", "a.txt")
+	r.Git("tag", "-a", "-m", "v1", "v1")
+	commit("2020-03-01", "workflow", ".github/workflows/ci.yml")
+	r.SetDate("2020-04-01T12:00:00Z")
+	r.Git("merge", "-q", "--no-ff", "side", "-m", "merge side")
+	commit("2020-05-01", "nested go.mod", "tools/go.mod")
+
+	return r.Dir
+}
+
+// policyFixtureCommits returns the commits of the fixture repository by subject, which
+// all lack a signature, the verdicts set in verdicts aside.
+func policyFixtureCommits(t *testing.T, dir string, verdicts map[string]io.Verdict) ([]io.Commit, []io.Commit, map[string]string) {
+	t.Helper()
+	commits, err := vcs.GetCommitsFromBranch(context.Background(), dir, "main", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unsigned := []io.Commit{}
+	hashes := make(map[string]string, len(commits))
+	for i := range commits {
+		c := &commits[i]
+		if v, ok := verdicts[c.Subject]; ok {
+			c.Verdict = v
+		}
+		if c.Verdict == io.VerdictMissing {
+			unsigned = append(unsigned, *c)
+		}
+		hashes[c.Subject] = c.GitOID
+	}
+	if len(commits) != 6 {
+		t.Fatalf("got %d commits, want 6", len(commits))
+	}
+	return commits, unsigned, hashes
+}
+
+func subjects(commits []io.Commit) []string {
+	res := make([]string, len(commits))
+	for i, c := range commits {
+		res[i] = c.Subject
+	}
+	slices.Sort(res)
+	return res
+}
+
+func violationsBySubject(violations []io.SignatureViolation) map[string][]string {
+	res := make(map[string][]string, len(violations))
+	for _, v := range violations {
+		res[v.Commit.Subject] = v.Reasons
+	}
+	return res
+}
+
+func TestApplySignaturePolicyCutoff(t *testing.T) {
+	dir := policyFixtureRepo(t)
+	_, _, hashes := policyFixtureCommits(t, dir, nil)
+
+	tests := []struct {
+		name  string
+		since string
+		// subjects of the commits which require a signature
+		want []string
+	}{
+		{"no cutoff", "", []string{"merge side", "nested go.mod", "root", "side", "tagged", "workflow"}},
+		// the side branch has been committed before the tag, but merged after it
+		{"tag", "v1", []string{"merge side", "nested go.mod", "side", "tagged", "workflow"}},
+		{"commit", hashes["workflow"], []string{"merge side", "nested go.mod", "side", "workflow"}},
+		// the side branch has been committed before the date, so it is informational
+		{"date", "2020-02-01", []string{"merge side", "nested go.mod", "tagged", "workflow"}},
+		{"RFC 3339 date", "2020-03-01T12:00:00Z", []string{"merge side", "nested go.mod", "workflow"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			commits, unsigned, _ := policyFixtureCommits(t, dir, nil)
+			violations, informational, err := applySignaturePolicy(context.Background(), io.SignaturePolicy{Since: tt.since}, dir, "main", "", commits, unsigned)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := make([]string, 0, len(violations))
+			for s, reasons := range violationsBySubject(violations) {
+				if !reflect.DeepEqual(reasons, []string{SignatureRequired}) {
+					t.Errorf("commit %q violates the policy with %v, want %v", s, reasons, []string{SignatureRequired})
+				}
+				got = append(got, s)
+			}
+			slices.Sort(got)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("violating commits = %v, want %v", got, tt.want)
+			}
+			// all commits are unsigned, so the remaining ones are informational
+			if len(informational)+len(violations) != len(unsigned) || slices.ContainsFunc(subjects(informational), func(s string) bool { return slices.Contains(tt.want, s) }) {
+				t.Errorf("informational commits = %v", subjects(informational))
+			}
+		})
+	}
+}
+
+func TestApplySignaturePolicyCutoffUnknown(t *testing.T) {
+	dir := policyFixtureRepo(t)
+	commits, unsigned, _ := policyFixtureCommits(t, dir, nil)
+	for _, since := range []string{"v2", "0123456789abcdef0123456789abcdef01234567", "--all"} {
+		if _, _, err := applySignaturePolicy(context.Background(), io.SignaturePolicy{Since: since}, dir, "main", "", commits, unsigned); err == nil {
+			t.Errorf("applySignaturePolicy succeeded with cutoff %q", since)
+		}
+	}
+}
+
+func TestApplySignaturePolicyPaths(t *testing.T) {
+	dir := policyFixtureRepo(t)
+	verdicts := map[string]io.Verdict{
+		"root":     io.VerdictUntrusted,
+		"tagged":   io.VerdictUntrusted,
+		"workflow": io.VerdictTrusted,
+		// signed, but not trusted
+		"nested go.mod": io.VerdictUntrusted,
+	}
+
+	tests := []struct {
+		name  string
+		paths []string
+		want  map[string][]string
+	}{
+		{
+			// matches nested directories and the merge of the side branch, which changed the files
+			name:  "double star",
+			paths: []string{".github/workflows/**"},
+			want: map[string][]string{
+				"side":       {SignatureRequired, TrustedSignatureRequired},
+				"merge side": {SignatureRequired, TrustedSignatureRequired},
+			},
+		},
+		{
+			name:  "single star doesn't match nested directories",
+			paths: []string{".github/workflows/*"},
+			want: map[string][]string{
+				"side":       {SignatureRequired},
+				"merge side": {SignatureRequired, TrustedSignatureRequired},
+			},
+		},
+		{
+			name:  "double star matches any directory",
+			paths: []string{"**/go.mod"},
+			want: map[string][]string{
+				"side":          {SignatureRequired},
+				"merge side":    {SignatureRequired},
+				"nested go.mod": {TrustedSignatureRequired},
+			},
+		},
+		{
+			name:  "top level file",
+			paths: []string{"go.mod"},
+			want: map[string][]string{
+				"side":       {SignatureRequired},
+				"merge side": {SignatureRequired},
+			},
+		},
+		{
+			name:  "pathspec magic",
+			paths: []string{":(glob)tools/*.mod"},
+			want: map[string][]string{
+				"side":          {SignatureRequired},
+				"merge side":    {SignatureRequired},
+				"nested go.mod": {TrustedSignatureRequired},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			commits, unsigned, _ := policyFixtureCommits(t, dir, verdicts)
+			policy := io.SignaturePolicy{Since: "v1", Paths: tt.paths}
+			violations, informational, err := applySignaturePolicy(context.Background(), policy, dir, "main", "", commits, unsigned)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := violationsBySubject(violations); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("violations = %v, want %v", got, tt.want)
+			}
+			if got := strings.Join(subjects(informational), ","); got != "" {
+				t.Errorf("informational commits = %v, want none", got)
+			}
+		})
+	}
+}
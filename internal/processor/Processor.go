@@ -1,12 +1,16 @@
 package processor
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"log/slog"
 	"os"
 	"path"
+	"project-integrity-calculator/internal/forge"
 	"project-integrity-calculator/internal/gh"
 	"project-integrity-calculator/internal/io"
+	"project-integrity-calculator/internal/score"
 	"project-integrity-calculator/internal/vcs"
 	"time"
 
@@ -16,82 +20,224 @@ import (
 type RepoConfig struct {
 	Owner, Repo, Branch, Token, ClonePath, Out string
 	IgnoreFirstCommits, FilterResults          bool
+	// Forge hosting the repository. Defaults to GitHub.
+	// For GitLab, Owner is the namespace of the project, e.g., group/subgroup.
+	Forge forge.Kind
+	// Base URL of the forge, e.g., https://gitlab.example.com. Required for Gitea and Gerrit,
+	// defaults to gitlab.com for GitLab. GitHub is configured with GraphQLURL and RestURL.
+	ForgeURL string
+	// Consider the commits of all patch sets of a Gerrit change as reviewed,
+	// not only the commit of the final patch set.
+	AllPatchSets bool
+	// Path of an existing bare clone or mirror which is analyzed instead of cloning the repository.
+	// Its branches are overwritten from origin, it is never deleted. Required for local repositories,
+	// which are not updated.
+	RepoPath string
+	// Keep the clone in ClonePath after the analysis and update it in later runs
+	// instead of cloning the repository again.
+	KeepClone bool
+	// JSON or JSON Lines file with the PRs of a local repository
+	Manifest string
+	// Result of a previous analysis of the branch. If set, only commits after its Head
+	// and PRs merged after its LastMergedAt are analyzed and merged into the result.
+	Previous *io.Repo
+	// Cache of patch ids, which can be shared between repositories and runs.
+	// An in-memory cache is used for the repository if nil.
+	PatchIds *vcs.PatchIdCache
+	// Weights used to calculate the Code Integrity Score.
+	// Defaults to score.DefaultWeights() if nil.
+	Weights *score.Weights
+	// Authenticate as GitHub App installation instead of using Token if AppID is set.
+	// The installation of the app for Owner/Repo is used if InstallationID is zero.
+	AppID, AppKeyPath string
+	InstallationID    int64
+	// Client of the forge API, which is shared between repositories to share the
+	// rate limit and the authentication. A client is created from the config if nil.
+	Client *gh.Client
+	// Endpoints of the GitHub API. Default to github.com if empty.
+	GraphQLURL, RestURL string
+	// Directory of the GitHub API response cache. No cache is used if empty.
+	CacheDir    string
+	CacheMode   gh.CacheMode
+	CacheMaxAge time.Duration
+	// Maximum duration of the analysis of the repository. Not limited if zero.
+	Timeout time.Duration
+	// OpenPGP keyring and SSH allowed signers file with the trusted signing keys.
+	// No key is trusted if both are empty.
+	GPGKeyring, AllowedSigners string
+	// Fetch the public signing keys of the contributors from the forge, so their
+	// signatures are valid, but not trusted. Only supported for GitHub.
+	FetchSigningKeys bool
+	// Fetch the verification of the signatures by the forge and reconcile it with the
+	// verification by git. Only supported for GitHub.
+	ForgeSignatures bool
+	// Policy which commits have to be signed. All commits have to be signed if nil.
+	SignaturePolicy *io.SignaturePolicy
 }
 
-func ProcessRepo(config RepoConfig) (*io.Repo, error) {
+// ProcessRepo analyzes the repository described by config. The analysis is stopped
+// with the error of ctx if ctx is done or config.Timeout has passed. The temporary
+// clone of the repository is removed in any case.
+func ProcessRepo(ctx context.Context, config RepoConfig) (*io.Repo, error) {
 
 	logger := slog.Default()
 	timer := time.Now()
 	logger.Info("Started processing of", "repo with config", config)
 
-	r, err := gh.GetRepoInfo(config.Owner, config.Repo, config.Token)
+	if config.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, config.Timeout)
+		defer cancel()
+	}
+
+	f, err := newForge(config)
+	if err != nil {
+		return nil, err
+	}
+	r, err := f.GetRepoInfo(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	// set up clone path and clone repo
-	dir := config.ClonePath
-	if dir == "" {
-		dir = path.Join(os.TempDir(), "repos")
+	auth := vcs.Auth(f.GitAuth)
+	dir, created, err := prepareRepo(ctx, config, r.CloneUrl, auth)
+	// only directories created by this run are removed, existing directories never are
+	if created != "" {
+		defer func() {
+			if err := os.RemoveAll(created); err != nil {
+				slog.Default().Warn("Failed to remove temporary directory", "dir", created, "error", err)
+			}
+		}()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	branch := config.Branch
+	if config.Branch == "" {
+		branch = r.DefaultBranch
 	}
 
-	err = os.MkdirAll(dir, os.ModePerm)
+	head, err := vcs.GetHead(ctx, dir, branch)
 	if err != nil {
 		return nil, err
 	}
 
-	err = vcs.CloneRepo(r.CloneUrl, dir)
+	previous, since := continueFrom(ctx, config.Previous, config.SignaturePolicy, dir, branch, head)
+	exclude := ""
+	if previous != nil {
+		exclude = previous.Head
+		logger.Info("Continuing previous analysis", "commits", previous.Head+".."+branch, "PRs merged after", since)
+	}
+
+	cache := config.PatchIds
+	if cache == nil {
+		cache = vcs.NewPatchIdCache(10_000_000)
+	}
+	defer cache.LogStats()
+
+	keyring, err := newKeyring(ctx, config, f)
 	if err != nil {
 		return nil, err
 	}
 	defer func() {
-		if err := os.RemoveAll(dir); err != nil {
-			slog.Default().Warn("Failed to remove temporary directory", "dir", dir, "error", err)
+		if err := keyring.Close(); err != nil {
+			slog.Default().Warn("Failed to remove keyring", "error", err)
 		}
 	}()
 
-	branch := config.Branch
-	if config.Branch == "" {
-		branch = r.DefaultBranch
-	}
-
-	cache := vcs.NewPatchIdCache(10_000_000)
 	methodTimer := time.Now()
-	patchIdToCommit, unsignedCommits, err := vcs.GetPatchIdAndUnsignedCommits(dir, branch, cache)
+	allCommits, err := vcs.GetCommitsFromBranch(ctx, dir, branch, exclude, keyring)
 	if err != nil {
 		return nil, err
 	}
+	var disagreements []io.SignatureDisagreement
+	if config.ForgeSignatures {
+		disagreements, err = reconcileSignatures(ctx, f, config.Forge, allCommits)
+		if err != nil {
+			return nil, err
+		}
+	}
+	patchIdToCommit, unsignedCommits, err := vcs.GetPatchIdAndUnsignedCommits(ctx, dir, branch, allCommits, cache)
+	if err != nil {
+		return nil, err
+	}
+	var violations []io.SignatureViolation
+	if config.SignaturePolicy != nil {
+		var informational []io.Commit
+		violations, informational, err = applySignaturePolicy(ctx, *config.SignaturePolicy, dir, branch, exclude, allCommits, *unsignedCommits)
+		if err != nil {
+			return nil, err
+		}
+		unsignedCommits = &informational
+	}
 	numberCommits := len(*patchIdToCommit)
+	signatures := make(map[io.Verdict]int)
+	for _, c := range *patchIdToCommit {
+		signatures[c.Verdict]++
+	}
 
 	elapsed := time.Since(methodTimer)
 	logger.Info("query all commits", "time", elapsed)
 
 	methodTimer = time.Now()
-	prIter := gh.GetPullRequests(config.Owner, config.Repo, branch, config.Token)
+	prIter := f.GetPullRequests(ctx, branch, since)
 	var work func(p *[]gh.PR) (*WorkerResult, error)
 	if config.IgnoreFirstCommits {
-		work = WorkerWithNewestPr(dir, cache)
+		work = WorkerWithNewestPr(ctx, dir, cache, auth)
 	} else {
-		work = WorkerWithoutNewestPr(dir, cache)
+		work = WorkerWithoutNewestPr(ctx, dir, cache, auth)
 	}
 
-	noOfForcePushes, _ := gh.GetForcePushInfo(config.Owner, config.Repo, config.Token, branch)
+	noOfForcePushes, forcePushErr := f.GetForcePushInfo(ctx, branch)
 
 	worker := beehive.Worker[[]gh.PR, WorkerResult]{
 		Work: work,
 	}
 
 	var firstPR *gh.PR = nil
+	// commits which are only part of PRs without an approving review
+	unreviewed := make(map[string]*io.Commit)
+	// findings of the previous analysis might be resolved by new PRs
+	if previous != nil {
+		if err := seedPrevious(ctx, previous, dir, cache, *patchIdToCommit, unreviewed); err != nil {
+			return nil, err
+		}
+	}
+	var lastMergedAt time.Time
+	numberPRs := 0
+	reviews := io.ReviewStats{}
+	selfMergedPRs := []io.PullRequest{}
+	incompleteReviews := 0
 	// this implementation relays on the fact that there is only one collector at all times so no
 	// race conditions can happen
 	collect := func(workerResults []*WorkerResult) error {
 		logger.Debug("Collecting hashes", "bufferedHashs", workerResults)
 		for i := range workerResults {
 			res := workerResults[i]
+			// a commit which is part of any approved PR is considered reviewed,
+			// even if it has been part of a PR without approval before.
 			for _, h := range res.PatchIds {
 				delete(*patchIdToCommit, h)
+				delete(unreviewed, h)
 			}
-			if config.IgnoreFirstCommits && (firstPR == nil || (res.NewestPr != nil && res.NewestPr.MergedAt < firstPR.MergedAt)) {
+			for _, h := range res.UnreviewedPatchIds {
+				if c, ok := (*patchIdToCommit)[h]; ok {
+					unreviewed[h] = c
+					delete(*patchIdToCommit, h)
+				}
+			}
+			numberPRs += res.NumberPRs
+			reviews.Approved += res.Reviews.Approved
+			reviews.CommentedOnly += res.Reviews.CommentedOnly
+			reviews.ChangesRequested += res.Reviews.ChangesRequested
+			reviews.Unreviewed += res.Reviews.Unreviewed
+			incompleteReviews += res.IncompleteReviews
+			selfMergedPRs = append(selfMergedPRs, res.SelfMergedPRs...)
+			if res.LastMergedAt.After(lastMergedAt) {
+				lastMergedAt = res.LastMergedAt
+			}
+			if config.IgnoreFirstCommits && (firstPR == nil || (res.NewestPr != nil && res.NewestPr.MergedAt.Before(firstPR.MergedAt))) {
 				firstPR = res.NewestPr
 			}
 		}
@@ -103,18 +249,51 @@ func ProcessRepo(config RepoConfig) (*io.Repo, error) {
 	// memory profiling for the Benchmark showed some larger memory spikes so we limit the number of worker to 6
 	// which works for our 256GB RAM VM
 	numWorker := 6
-	dispatcher := beehive.NewDispatcher(worker, prIter, *collector, beehive.DispatcherConfig{NumWorker: &numWorker})
-	dispatcher.Dispatch()
+	dispatcher := beehive.NewDispatcher(worker, prIter.All(), *collector, beehive.DispatcherConfig{NumWorker: &numWorker})
+	errs := dispatcher.Dispatch()
+
+	// PRs which haven't been processed due to the cancellation would be missing
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	// without all PRs every commit of a missing PR would be falsely reported as commit without PR
+	if err := prIter.Err(); err != nil {
+		return nil, err
+	}
 
-	if config.IgnoreFirstCommits && firstPR != nil {
+	// PRs which couldn't be processed have the same effect, but we keep the
+	// result as the failures are typically limited to a few PRs
+	incomplete := false
+	if errs != nil {
+		incomplete = true
+		logger.Warn("Processing of some PRs failed. The result is incomplete.", "errors", *errs)
+	}
+	// the review status of PRs with missing reviews may be wrong
+	if incompleteReviews > 0 {
+		incomplete = true
+		logger.Warn("Reviews of some PRs couldn't be fetched. The result is incomplete.", "prs", incompleteReviews)
+	}
+	// zero force pushes would make the repository look more trustworthy than it is
+	if forcePushErr != nil {
+		incomplete = true
+		logger.Warn("Getting force pushes failed. The result is incomplete.", "err", forcePushErr)
+	}
+
+	// the commits before the first PR have already been ignored if the previous analysis found PRs
+	if config.IgnoreFirstCommits && firstPR != nil && (previous == nil || previous.Stats.NumberPRs == 0) {
 		logger.Info("First PR", "pr", *firstPR)
 		// identify commits before newest PRs and whitelist them
 		// newestPr.HeadRefOid
 		for h, k := range *patchIdToCommit {
-			if k.Date < firstPR.MergedAt {
+			if k.Date.Before(firstPR.MergedAt) {
 				delete(*patchIdToCommit, h)
 			}
 		}
+		for h, k := range unreviewed {
+			if k.Date.Before(firstPR.MergedAt) {
+				delete(unreviewed, h)
+			}
+		}
 	}
 
 	commitsWithoutPr := make([]io.Commit, 0, len(*patchIdToCommit))
@@ -122,6 +301,14 @@ func ProcessRepo(config RepoConfig) (*io.Repo, error) {
 		commitsWithoutPr = append(commitsWithoutPr, *c)
 	}
 
+	commitsWithUnreviewedPr := make([]io.Commit, 0, len(unreviewed))
+	for _, c := range unreviewed {
+		commitsWithUnreviewedPr = append(commitsWithUnreviewedPr, *c)
+	}
+
+	if previous != nil {
+		numberCommits += previous.Stats.NumberCommits
+	}
 	if config.FilterResults && len(commitsWithoutPr) > (numberCommits/2) {
 		return nil, errors.New("inconclusive result. More than 50% of the commits were identified")
 	}
@@ -130,27 +317,46 @@ func ProcessRepo(config RepoConfig) (*io.Repo, error) {
 	logger.Info("processed all PRs", "time", elapsed)
 
 	logger.Info("Number commits without PR", "number", len(*patchIdToCommit))
-
-	heads, err := vcs.GetCommitsFromHashs(dir, []string{branch})
-	head := ""
-	if err == nil && len(heads) == 1 {
-		head = heads[0].GitOID
-	}
+	logger.Info("Number commits only in PRs without approval", "number", len(unreviewed))
+	logger.Info("Number self-merged PRs", "number", len(selfMergedPRs))
 
 	repo := io.Repo{
-		Branch:            branch,
-		Url:               r.CloneUrl,
-		NumberForcePushes: noOfForcePushes,
-		Head:              head,
-		CommitsWithoutPR:  commitsWithoutPr,
-		UnsignedCommits:   *unsignedCommits,
+		Branch:                    branch,
+		Url:                       r.CloneUrl,
+		NumberForcePushes:         noOfForcePushes,
+		Head:                      head,
+		LastMergedAt:              lastMergedAt,
+		Incomplete:                incomplete,
+		CommitsWithoutPR:          commitsWithoutPr,
+		UnsignedCommits:           *unsignedCommits,
+		CommitsWithUnreviewedPR:   commitsWithUnreviewedPr,
+		SelfMergedPRs:             selfMergedPRs,
+		SignaturePolicy:           config.SignaturePolicy,
+		SignaturePolicyViolations: violations,
+		SignatureDisagreements:    disagreements,
 		Stats: io.Stats{
 			NumberCommits: numberCommits,
-			NumberPRs:     0,
+			NumberPRs:     numberPRs,
+			Reviews:       reviews,
+			Signatures:    signatures,
 			Stars:         r.Stars,
 			Languages:     r.Languages,
 		},
 	}
+	if previous != nil {
+		mergePrevious(&repo, previous)
+	}
+
+	weights := score.DefaultWeights()
+	if config.Weights != nil {
+		weights = *config.Weights
+	}
+	s, err := score.Calculate(repo, weights)
+	if err != nil {
+		return nil, err
+	}
+	repo.Score = *s
+	logger.Info("Code Integrity Score", "score", s.Value)
 
 	timerEnd := time.Since(timer)
 	logger.Info("Processing of repo finished", "repo", config.Repo, "time", timerEnd)
@@ -158,13 +364,115 @@ func ProcessRepo(config RepoConfig) (*io.Repo, error) {
 	return &repo, nil
 }
 
+// newKeyring creates the keyring the signatures of the commits are verified with.
+func newKeyring(ctx context.Context, config RepoConfig, f forge.Forge) (*vcs.Keyring, error) {
+	var known *gh.SigningKeys
+	if config.FetchSigningKeys {
+		source, ok := f.(forge.SigningKeySource)
+		if !ok {
+			return nil, fmt.Errorf("fetching signing keys isn't supported for %s", config.Forge)
+		}
+		var err error
+		known, err = source.GetSigningKeys(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return vcs.NewKeyring(ctx, config.GPGKeyring, config.AllowedSigners, known)
+}
+
+// prepareRepo returns the directory of the repository to analyze, which is either
+// config.RepoPath, the clone of a previous run, or a new clone. created is the
+// directory created for the clone, which has to be removed after the analysis.
+// It is empty if the clone is kept or no directory has been created.
+func prepareRepo(ctx context.Context, config RepoConfig, cloneUrl string, auth vcs.Auth) (dir, created string, err error) {
+	logger := slog.Default()
+
+	if config.RepoPath != "" {
+		if !vcs.IsRepo(ctx, config.RepoPath) {
+			// IsRepo fails as well if ctx is done
+			if err := ctx.Err(); err != nil {
+				return "", "", err
+			}
+			return "", "", fmt.Errorf("repo path %s is not a git repository", config.RepoPath)
+		}
+		// local repositories have no forge to fetch from
+		if config.Forge == forge.Local {
+			return config.RepoPath, "", nil
+		}
+		logger.Info("Fetching existing repository", "dir", config.RepoPath)
+		return config.RepoPath, "", vcs.FetchRepo(ctx, config.RepoPath, auth)
+	}
+
+	// set up clone path and clone repo
+	target := config.ClonePath
+	if target == "" {
+		target = path.Join(os.TempDir(), "repos")
+	}
+
+	if !config.KeepClone {
+		// the clone target might contain other files, so the clone gets a directory of its own
+		if err := os.MkdirAll(target, os.ModePerm); err != nil {
+			return "", "", err
+		}
+		dir, err := os.MkdirTemp(target, "clone")
+		if err != nil {
+			return "", "", err
+		}
+		// the clone URL points to the host of the forge the repository has been queried from
+		return dir, dir, vcs.CloneRepo(ctx, cloneUrl, dir, auth)
+	}
+
+	if vcs.IsRepo(ctx, target) {
+		// the clone target might contain a clone of another repository
+		origin, err := vcs.GetRemoteURL(ctx, target)
+		if err != nil {
+			return "", "", err
+		}
+		if origin != cloneUrl {
+			return "", "", fmt.Errorf("clone in %s is a clone of %s, not of %s", target, origin, cloneUrl)
+		}
+		logger.Info("Fetching clone of previous run", "dir", target)
+		return target, "", vcs.FetchRepo(ctx, target, auth)
+	}
+
+	// incomplete clones are removed, unless the clone target existed before
+	_, statErr := os.Stat(target)
+	existed := statErr == nil
+	if err := os.MkdirAll(target, os.ModePerm); err != nil {
+		return "", "", err
+	}
+	if err := vcs.CloneRepo(ctx, cloneUrl, target, auth); err != nil {
+		if existed {
+			return "", "", err
+		}
+		return "", target, err
+	}
+	return target, "", nil
+}
+
 type WorkerResult struct {
+	// patch ids of commits that are part of at least one approved PR
 	PatchIds []string
-	NewestPr *gh.PR
+	// patch ids of commits that are part of a PR without an approving review
+	UnreviewedPatchIds []string
+	NewestPr           *gh.PR
+	NumberPRs          int
+	Reviews            io.ReviewStats
+	SelfMergedPRs      []io.PullRequest
+	// number of PRs whose reviews couldn't be fetched completely
+	IncompleteReviews int
+	// merge time of the newest PR
+	LastMergedAt time.Time
 }
 
-func WorkerWithoutNewestPr(dir string, cache *vcs.PatchIdCache) func(p *[]gh.PR) (*WorkerResult, error) {
+// WorkerWithoutNewestPr returns a worker processing a page of PRs. The worker
+// fails without processing further PRs once ctx is done.
+func WorkerWithoutNewestPr(ctx context.Context, dir string, cache *vcs.PatchIdCache, auth vcs.Auth) func(p *[]gh.PR) (*WorkerResult, error) {
 	return func(p *[]gh.PR) (*WorkerResult, error) {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
 		if len(*p) == 0 {
 			return &WorkerResult{}, nil
 		}
@@ -172,30 +480,21 @@ func WorkerWithoutNewestPr(dir string, cache *vcs.PatchIdCache) func(p *[]gh.PR)
 		prs := *p
 		firstPR := prs[0]
 
-		commitsFromPrs, err := vcs.GetCommitShaForMergedPr(prs, dir)
+		res, err := processPrs(ctx, prs, dir, cache, auth)
 		if err != nil {
 			return nil, err
 		}
-		ids := make([]string, 0, len(*commitsFromPrs))
-		for _, cs := range *commitsFromPrs {
-			for c := range cs.Items() {
-				pi, err := cache.GetOrCreatePatchId(dir, c)
-				if err != nil || pi == "" {
-					slog.Default().Debug("Get patch id failed. Setting patch id to original commit id", "err", err)
-					pi = c
-				}
-				ids = append(ids, pi)
-			}
-		}
-		return &WorkerResult{
-			PatchIds: ids,
-			NewestPr: &firstPR,
-		}, nil
+		res.NewestPr = &firstPR
+		return res, nil
 	}
 }
 
-func WorkerWithNewestPr(dir string, cache *vcs.PatchIdCache) func(p *[]gh.PR) (*WorkerResult, error) {
+// WorkerWithNewestPr is like WorkerWithoutNewestPr, but finds the PR merged first.
+func WorkerWithNewestPr(ctx context.Context, dir string, cache *vcs.PatchIdCache, auth vcs.Auth) func(p *[]gh.PR) (*WorkerResult, error) {
 	return func(p *[]gh.PR) (*WorkerResult, error) {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
 		if len(*p) == 0 {
 			return &WorkerResult{}, nil
 		}
@@ -204,28 +503,79 @@ func WorkerWithNewestPr(dir string, cache *vcs.PatchIdCache) func(p *[]gh.PR) (*
 		newestPr := prs[0]
 
 		for i, pr := range prs {
-			if pr.MergedAt < newestPr.MergedAt {
+			if pr.MergedAt.Before(newestPr.MergedAt) {
 				newestPr = prs[i]
 			}
 		}
-		commitsFromPrs, err := vcs.GetCommitShaForMergedPr(prs, dir)
+
+		res, err := processPrs(ctx, prs, dir, cache, auth)
 		if err != nil {
 			return nil, err
 		}
-		ids := make([]string, 0, len(*commitsFromPrs))
-		for _, cs := range *commitsFromPrs {
-			for c := range cs.Items() {
-				pi, err := cache.GetOrCreatePatchId(dir, c)
-				if err != nil || pi == "" {
-					slog.Default().Debug("Get patch id failed. Setting patch id to original commit id", "err", err)
-					pi = c
-				}
-				ids = append(ids, pi)
+		res.NewestPr = &newestPr
+		return res, nil
+	}
+}
+
+// processPrs calculates the patch ids of all commits in prs and sorts
+// them by the review status of the PR they belong to.
+func processPrs(ctx context.Context, prs []gh.PR, dir string, cache *vcs.PatchIdCache, auth vcs.Auth) (*WorkerResult, error) {
+	commitsFromPrs, err := vcs.GetCommitShaForMergedPr(ctx, prs, dir, auth)
+	if err != nil {
+		return nil, err
+	}
+
+	hashes := make([]string, 0, len(*commitsFromPrs))
+	for _, cs := range *commitsFromPrs {
+		hashes = append(hashes, cs.Slice()...)
+	}
+	patchIds, err := cache.GetOrCreatePatchIds(ctx, dir, hashes)
+	if err != nil {
+		return nil, err
+	}
+
+	res := WorkerResult{
+		PatchIds:  make([]string, 0, len(*commitsFromPrs)),
+		NumberPRs: len(prs),
+	}
+	for _, pr := range prs {
+		if pr.MergedAt.After(res.LastMergedAt) {
+			res.LastMergedAt = pr.MergedAt
+		}
+
+		if pr.ReviewsIncomplete {
+			res.IncompleteReviews++
+		}
+		status := ClassifyReviews(pr)
+		addReviewStatus(&res.Reviews, status)
+
+		if reasons := DetectSelfMerge(pr); len(reasons) > 0 {
+			res.SelfMergedPRs = append(res.SelfMergedPRs, io.PullRequest{
+				Number:   pr.Number,
+				Title:    pr.Title,
+				Author:   pr.Author.Login,
+				MergedBy: pr.MergedBy.Login,
+				MergedAt: pr.MergedAt,
+				Reasons:  reasons,
+			})
+		}
+
+		cs, ok := (*commitsFromPrs)[pr.Number]
+		if !ok {
+			continue
+		}
+		for c := range cs.Items() {
+			pi := patchIds[c]
+			if pi == "" {
+				slog.Default().Debug("Patch id is empty. Setting patch id to original commit id", "commit", c)
+				pi = c
+			}
+			if status != Approved {
+				res.UnreviewedPatchIds = append(res.UnreviewedPatchIds, pi)
+			} else {
+				res.PatchIds = append(res.PatchIds, pi)
 			}
 		}
-		return &WorkerResult{
-			PatchIds: ids,
-			NewestPr: &newestPr,
-		}, nil
 	}
+	return &res, nil
 }
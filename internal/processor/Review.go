@@ -0,0 +1,117 @@
+package processor
+
+import (
+	"strconv"
+
+	"project-integrity-calculator/internal/gh"
+	"project-integrity-calculator/internal/io"
+)
+
+type ReviewStatus string
+
+const (
+	// Approved means a reviewer approved the PR and no reviewer's changes request is open.
+	Approved ReviewStatus = "APPROVED"
+	// CommentedOnly means the PR got reviews, but none of them approved or rejected it.
+	CommentedOnly ReviewStatus = "COMMENTED_ONLY"
+	// ChangesRequested means a reviewer's changes request is open and the PR was merged anyway.
+	ChangesRequested ReviewStatus = "CHANGES_REQUESTED"
+	// Unreviewed means the PR was merged without any review.
+	Unreviewed ReviewStatus = "UNREVIEWED"
+)
+
+// ClassifyReviews determines the ReviewStatus of a merged PR based on its reviews.
+// GitHub returns reviews in chronological order, so the last APPROVED or
+// CHANGES_REQUESTED review of each reviewer is their decision. A DISMISSED review
+// withdraws the decision of the reviewer, comments don't change it, and pending
+// reviews are ignored. Reviews of deleted accounts count as reviews of different reviewers.
+func ClassifyReviews(pr gh.PR) ReviewStatus {
+	decisions := make(map[string]string)
+	reviewed := false
+	for i, r := range pr.Reviews.Nodes {
+		reviewer := r.Author.Login
+		if reviewer == "" {
+			reviewer = "#" + strconv.Itoa(i)
+		}
+		switch r.State {
+		case "APPROVED", "CHANGES_REQUESTED":
+			decisions[reviewer] = r.State
+		case "DISMISSED":
+			delete(decisions, reviewer)
+		case "COMMENTED":
+			// comments don't change the decision of the reviewer
+		default:
+			continue
+		}
+		reviewed = true
+	}
+
+	approved := false
+	for _, d := range decisions {
+		if d == "CHANGES_REQUESTED" {
+			return ChangesRequested
+		}
+		approved = true
+	}
+	switch {
+	case approved:
+		return Approved
+	case reviewed:
+		return CommentedOnly
+	default:
+		return Unreviewed
+	}
+}
+
+// addReviewStatus increments the counter matching status in stats.
+func addReviewStatus(stats *io.ReviewStats, status ReviewStatus) {
+	switch status {
+	case Approved:
+		stats.Approved++
+	case CommentedOnly:
+		stats.CommentedOnly++
+	case ChangesRequested:
+		stats.ChangesRequested++
+	case Unreviewed:
+		stats.Unreviewed++
+	}
+}
+
+const (
+	// AuthorMerged means the author of the PR merged it themselves.
+	AuthorMerged = "AUTHOR_MERGED"
+	// SelfApproved means all approving reviews came from the author or a bot.
+	SelfApproved = "SELF_APPROVED"
+)
+
+// DetectSelfMerge returns the reasons why pr is considered to be self-merged.
+// An empty result means the PR has been merged or approved by someone else.
+// PRs of deleted accounts are never considered self-merged as their author is unknown.
+func DetectSelfMerge(pr gh.PR) []string {
+	reasons := []string{}
+	author := pr.Author.Login
+	if author == "" {
+		return reasons
+	}
+
+	if pr.MergedBy.Login == author {
+		reasons = append(reasons, AuthorMerged)
+	}
+
+	approvals := 0
+	selfApprovals := 0
+	for _, r := range pr.Reviews.Nodes {
+		if r.State != "APPROVED" {
+			continue
+		}
+		approvals++
+		if r.Author.Login == author || r.Author.IsBot() {
+			selfApprovals++
+		}
+	}
+	if approvals > 0 && approvals == selfApprovals {
+		reasons = append(reasons, SelfApproved)
+	}
+
+	return reasons
+}
@@ -0,0 +1,78 @@
+package processor
+
+import (
+	"slices"
+	"testing"
+
+	"project-integrity-calculator/internal/gh"
+)
+
+func pr(author, mergedBy string, reviews ...gh.Review) gh.PR {
+	p := gh.PR{
+		Author:   gh.Actor{Login: author, Type: "User"},
+		MergedBy: gh.Actor{Login: mergedBy, Type: "User"},
+	}
+	p.Reviews.Nodes = reviews
+	return p
+}
+
+func review(state, author string) gh.Review {
+	return gh.Review{State: state, Author: gh.Actor{Login: author, Type: "User"}}
+}
+
+func TestClassifyReviews(t *testing.T) {
+	tests := []struct {
+		name    string
+		reviews []gh.Review
+		want    ReviewStatus
+	}{
+		{"no reviews", nil, Unreviewed},
+		{"pending review", []gh.Review{review("PENDING", "bob")}, Unreviewed},
+		{"comment", []gh.Review{review("COMMENTED", "bob")}, CommentedOnly},
+		{"dismissed", []gh.Review{review("DISMISSED", "bob")}, CommentedOnly},
+		{"approved", []gh.Review{review("APPROVED", "bob")}, Approved},
+		{"changes requested", []gh.Review{review("CHANGES_REQUESTED", "bob")}, ChangesRequested},
+		{"approved after changes requested", []gh.Review{review("CHANGES_REQUESTED", "bob"), review("APPROVED", "bob")}, Approved},
+		{"changes requested after approval", []gh.Review{review("APPROVED", "bob"), review("CHANGES_REQUESTED", "carol")}, ChangesRequested},
+		{"comment after approval", []gh.Review{review("APPROVED", "bob"), review("COMMENTED", "carol")}, Approved},
+		{"comment after changes requested", []gh.Review{review("CHANGES_REQUESTED", "bob"), review("COMMENTED", "bob")}, ChangesRequested},
+		{"approved by other reviewer after changes requested", []gh.Review{review("CHANGES_REQUESTED", "bob"), review("APPROVED", "carol")}, ChangesRequested},
+		{"approval dismissed", []gh.Review{review("APPROVED", "bob"), review("DISMISSED", "bob")}, CommentedOnly},
+		{"approval dismissed, other approval left", []gh.Review{review("APPROVED", "bob"), review("APPROVED", "carol"), review("DISMISSED", "bob")}, Approved},
+		{"changes request dismissed", []gh.Review{review("CHANGES_REQUESTED", "bob"), review("APPROVED", "carol"), review("DISMISSED", "bob")}, Approved},
+		{"approved again after dismissal", []gh.Review{review("APPROVED", "bob"), review("DISMISSED", "bob"), review("APPROVED", "bob")}, Approved},
+		{"deleted reviewers", []gh.Review{review("CHANGES_REQUESTED", ""), review("APPROVED", "")}, ChangesRequested},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ClassifyReviews(pr("alice", "bob", tt.reviews...)); got != tt.want {
+				t.Errorf("ClassifyReviews() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDetectSelfMerge(t *testing.T) {
+	bot := gh.Review{State: "APPROVED", Author: gh.Actor{Login: "renovate", Type: "Bot"}}
+	tests := []struct {
+		name string
+		pr   gh.PR
+		want []string
+	}{
+		{"merged by other", pr("alice", "bob", review("APPROVED", "bob")), []string{}},
+		{"merged by author", pr("alice", "alice", review("APPROVED", "bob")), []string{AuthorMerged}},
+		{"approved by author", pr("alice", "bob", review("APPROVED", "alice")), []string{SelfApproved}},
+		{"approved by bot", pr("alice", "bob", bot), []string{SelfApproved}},
+		{"approved by author and other", pr("alice", "bob", review("APPROVED", "alice"), review("APPROVED", "carol")), []string{}},
+		{"merged and approved by author", pr("alice", "alice", review("APPROVED", "alice")), []string{AuthorMerged, SelfApproved}},
+		{"unreviewed", pr("alice", "bob"), []string{}},
+		{"deleted author", pr("", ""), []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DetectSelfMerge(tt.pr); !slices.Equal(got, tt.want) {
+				t.Errorf("DetectSelfMerge() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"project-integrity-calculator/internal/forge"
+	"project-integrity-calculator/internal/io"
+)
+
+// reconcileSignatures adds the verification of the signatures by the forge to the commits and
+// returns the commits whose signatures are valid according to either git or the forge, but not both.
+// Signatures git can't verify are accepted if the forge considers them valid, e.g., signatures
+// of the web interface or with keys only the forge knows. Bad, revoked, and expired signatures
+// are never accepted, as the forge might not know about the revocation of a key.
+func reconcileSignatures(ctx context.Context, f forge.Forge, kind forge.Kind, commits []io.Commit) ([]io.SignatureDisagreement, error) {
+	source, ok := f.(forge.SignatureSource)
+	if !ok {
+		return nil, fmt.Errorf("verifying signatures isn't supported for %s", kind)
+	}
+
+	hashes := make([]string, len(commits))
+	for i, c := range commits {
+		hashes[i] = c.GitOID
+	}
+	signatures, err := source.GetCommitSignatures(ctx, hashes)
+	if err != nil {
+		return nil, err
+	}
+
+	disagreements := []io.SignatureDisagreement{}
+	for i := range commits {
+		c := &commits[i]
+		s, ok := signatures[c.GitOID]
+		if !ok {
+			continue
+		}
+		c.ForgeSignature = &io.ForgeSignature{
+			State:         s.State,
+			SignedByForge: s.WasSignedByGitHub,
+			Signer:        s.Signer,
+		}
+
+		localValid := c.Verdict == io.VerdictTrusted || c.Verdict == io.VerdictUntrusted
+		forgeValid := s.State == "VALID"
+		if localValid == forgeValid {
+			continue
+		}
+		disagreements = append(disagreements, io.SignatureDisagreement{
+			GitOID: c.GitOID,
+			Local:  c.Verdict,
+			Forge:  s.State,
+		})
+		if forgeValid && c.Verdict == io.VerdictUnverifiable {
+			c.Verdict = io.VerdictUntrusted
+		}
+	}
+	slog.Default().Info("Number signatures verified differently by the forge", "number", len(disagreements))
+
+	return disagreements, nil
+}
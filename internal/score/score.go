@@ -0,0 +1,114 @@
+package score
+
+import (
+	"errors"
+	"project-integrity-calculator/internal/io"
+)
+
+// Names of the sub-scores stored in io.Score.SubScores
+const (
+	CommitsWithPR   = "CommitsWithPR"
+	ReviewedCommits = "ReviewedCommits"
+	SignedCommits   = "SignedCommits"
+	ForcePushes     = "ForcePushes"
+)
+
+// Weights configures how much each signal contributes to the overall score.
+// The weights don't need to sum up to one as they are normalized during
+// the calculation.
+type Weights struct {
+	CommitsWithPR   float64
+	ReviewedCommits float64
+	SignedCommits   float64
+	ForcePushes     float64
+}
+
+// DefaultWeights returns the weights used if no weights are configured.
+// Commits without a PR are the strongest indicator for a violation of
+// the code integrity, followed by unsigned commits, commits without
+// review, and force pushes.
+func DefaultWeights() Weights {
+	return Weights{
+		CommitsWithPR:   0.4,
+		ReviewedCommits: 0.2,
+		SignedCommits:   0.25,
+		ForcePushes:     0.15,
+	}
+}
+
+// Calculate computes the Code Integrity Score for the given repo.
+// All sub-scores are normalized to [0, 1] with 1 being the best value:
+//
+//	CommitsWithPR   = 1 - len(CommitsWithoutPR) / NumberCommits
+//	ReviewedCommits = 1 - len(CommitsWithUnreviewedPR) / NumberCommits
+//	SignedCommits   = 1 - len(UnsignedCommits) / NumberCommits
+//	                  or 1 - len(SignaturePolicyViolations) / NumberCommits with a SignaturePolicy
+//	ForcePushes     = 1 / (1 + NumberForcePushes)
+//
+// The overall score is the weighted mean of all sub-scores:
+//
+//	Value = sum(weight_i * subScore_i) / sum(weight_i)
+//
+// Repositories without commits get a sub-score of 1 for all commit based signals.
+func Calculate(repo io.Repo, w Weights) (*io.Score, error) {
+	if w.CommitsWithPR < 0 || w.ReviewedCommits < 0 || w.SignedCommits < 0 || w.ForcePushes < 0 {
+		return nil, errors.New("weights must not be negative")
+	}
+
+	// unsigned commits which aren't required to be signed are informational
+	unsigned := len(repo.UnsignedCommits)
+	if repo.SignaturePolicy != nil {
+		unsigned = len(repo.SignaturePolicyViolations)
+	}
+
+	subScores := []io.SubScore{
+		{
+			Name:   CommitsWithPR,
+			Value:  commitRatio(len(repo.CommitsWithoutPR), repo.Stats.NumberCommits),
+			Weight: w.CommitsWithPR,
+		},
+		{
+			Name:   ReviewedCommits,
+			Value:  commitRatio(len(repo.CommitsWithUnreviewedPR), repo.Stats.NumberCommits),
+			Weight: w.ReviewedCommits,
+		},
+		{
+			Name:   SignedCommits,
+			Value:  commitRatio(unsigned, repo.Stats.NumberCommits),
+			Weight: w.SignedCommits,
+		},
+		{
+			Name:   ForcePushes,
+			Value:  1 / (1 + float64(repo.NumberForcePushes)),
+			Weight: w.ForcePushes,
+		},
+	}
+
+	weightSum := 0.0
+	value := 0.0
+	for _, s := range subScores {
+		weightSum += s.Weight
+		value += s.Weight * s.Value
+	}
+
+	if weightSum == 0 {
+		return nil, errors.New("at least one weight must be greater than zero")
+	}
+
+	return &io.Score{
+		Value:     value / weightSum,
+		SubScores: subScores,
+	}, nil
+}
+
+// commitRatio returns the share of commits which are not affected by a violation.
+func commitRatio(violations, numberCommits int) float64 {
+	if numberCommits <= 0 {
+		return 1
+	}
+	r := 1 - float64(violations)/float64(numberCommits)
+	if r < 0 {
+		return 0
+	}
+	return r
+}
@@ -0,0 +1,107 @@
+package score
+
+import (
+	"math"
+	"testing"
+
+	"project-integrity-calculator/internal/io"
+)
+
+func TestCommitRatio(t *testing.T) {
+	tests := []struct {
+		name          string
+		violations    int
+		numberCommits int
+		want          float64
+	}{
+		{"no commits", 0, 0, 1},
+		{"violations without commits", 3, 0, 1},
+		{"no violations", 0, 10, 1},
+		{"some violations", 3, 10, 0.7},
+		{"only violations", 10, 10, 0},
+		{"more violations than commits", 12, 10, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := commitRatio(tt.violations, tt.numberCommits); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("commitRatio(%d, %d) = %v, want %v", tt.violations, tt.numberCommits, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCalculate(t *testing.T) {
+	// 2 of 10 commits without PR, 5 unreviewed, 10 unsigned, and 1 force push
+	repo := io.Repo{
+		NumberForcePushes:       1,
+		Stats:                   io.Stats{NumberCommits: 10},
+		CommitsWithoutPR:        make([]io.Commit, 2),
+		CommitsWithUnreviewedPR: make([]io.Commit, 5),
+		UnsignedCommits:         make([]io.Commit, 10),
+	}
+
+	tests := []struct {
+		name    string
+		repo    io.Repo
+		weights Weights
+		want    float64
+		wantErr bool
+	}{
+		{
+			name:    "default weights",
+			repo:    repo,
+			weights: DefaultWeights(),
+			want:    0.4*0.8 + 0.2*0.5 + 0.25*0 + 0.15*0.5,
+		},
+		{
+			name:    "weights are normalized",
+			repo:    repo,
+			weights: Weights{CommitsWithPR: 1, ReviewedCommits: 1, SignedCommits: 1, ForcePushes: 1},
+			want:    (0.8 + 0.5 + 0 + 0.5) / 4,
+		},
+		{
+			name:    "single signal",
+			repo:    repo,
+			weights: Weights{ReviewedCommits: 2},
+			want:    0.5,
+		},
+		{
+			name:    "no commits",
+			repo:    io.Repo{},
+			weights: DefaultWeights(),
+			want:    1,
+		},
+		{
+			name:    "all weights zero",
+			repo:    repo,
+			weights: Weights{},
+			wantErr: true,
+		},
+		{
+			name:    "negative weight",
+			repo:    repo,
+			weights: Weights{CommitsWithPR: 1, ForcePushes: -1},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Calculate(tt.repo, tt.weights)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Calculate() = %v, want error", got.Value)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if math.Abs(got.Value-tt.want) > 1e-9 {
+				t.Errorf("Calculate() = %v, want %v", got.Value, tt.want)
+			}
+			if len(got.SubScores) != 4 {
+				t.Errorf("got %d sub-scores, want 4", len(got.SubScores))
+			}
+		})
+	}
+}
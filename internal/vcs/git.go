@@ -1,10 +1,12 @@
 package vcs
 
 import (
+	"context"
 	"fmt"
 	"log/slog"
-	"os/exec"
+	"path/filepath"
 	"strings"
+	"time"
 	"unicode"
 
 	"project-integrity-calculator/internal/gh"
@@ -13,7 +15,13 @@ import (
 	"github.com/hashicorp/go-set/v3"
 )
 
-func GetCommitShaForMergedPr(prs []gh.PR, repoDir string) (*map[int]*set.Set[string], error) {
+// Auth returns the value of the HTTP Authorization header git sends to the remote,
+// e.g., "Basic <base64 credentials>". It is called for every git command accessing
+// the remote, so expiring credentials can be renewed in between.
+// Git accesses the remote anonymously if Auth is nil or returns an empty value.
+type Auth func(ctx context.Context) (string, error)
+
+func GetCommitShaForMergedPr(ctx context.Context, prs []gh.PR, repoDir string, auth Auth) (*map[int]*set.Set[string], error) {
 	logger := slog.Default()
 
 	if len(prs) == 0 {
@@ -21,7 +29,7 @@ func GetCommitShaForMergedPr(prs []gh.PR, repoDir string) (*map[int]*set.Set[str
 		return &map[int]*set.Set[string]{}, nil
 	}
 
-	err := fetchAllRefs(prs, repoDir)
+	err := fetchAllRefs(ctx, prs, repoDir, auth)
 	if err != nil {
 		return nil, err
 	}
@@ -29,7 +37,10 @@ func GetCommitShaForMergedPr(prs []gh.PR, repoDir string) (*map[int]*set.Set[str
 	res := make(map[int]*set.Set[string], len(prs))
 
 	for _, pr := range prs {
-		newCommits, err := getCommitHashsForPr(repoDir, pr)
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		newCommits, err := getCommitHashsForPr(ctx, repoDir, pr)
 		if err != nil {
 			continue
 		}
@@ -40,49 +51,83 @@ func GetCommitShaForMergedPr(prs []gh.PR, repoDir string) (*map[int]*set.Set[str
 	return &res, nil
 }
 
-func fetchAllRefs(prs []gh.PR, dir string) error {
+func fetchAllRefs(ctx context.Context, prs []gh.PR, dir string, auth Auth) error {
 	if len(prs) == 0 {
 		return nil
 	}
 
-	args := []string{"fetch", "origin"}
+	args := []string{"fetch", "origin", "--"}
+	addRef := func(pr int, ref string) {
+		if ref == "" {
+			return
+		}
+		// refs are returned by the forge, so they can't be trusted
+		if !validRef(ref) {
+			slog.Default().Warn("Ignoring invalid head ref", "pr number", pr, "ref", ref)
+			return
+		}
+		args = append(args, fmt.Sprintf("+%s:%s", ref, ref))
+	}
 	for _, pr := range prs {
-		if pr.State == "MERGED" {
-			prn := pr.Number
-			args = append(args, fmt.Sprintf("pull/%d/head:pull/%d", prn, prn))
+		if pr.State != "MERGED" {
+			continue
+		}
+		addRef(pr.Number, pr.HeadRef)
+		for _, r := range pr.Revisions {
+			addRef(pr.Number, r.HeadRef)
 		}
 	}
+	if len(args) == 3 {
+		return nil
+	}
 
-	// git fetch origin pull/<pr_number>/head:<local_branch_name> ...
-	cmd := exec.Command("git", args...)
-	cmd.Dir = dir
-	o, err := cmd.CombinedOutput()
-	if err != nil {
-		slog.Default().Error("Git fetch failed", "target dir", dir, "output", o)
+	// git fetch origin -- +refs/pull/<pr_number>/head:refs/pull/<pr_number>/head ...
+	cmd := newGitCmd(ctx, dir, remoteTimeout, args...)
+	if err := cmd.withAuth(auth); err != nil {
+		return err
+	}
+	if err := cmd.run(); err != nil {
+		slog.Default().Error("Git fetch failed", "target dir", dir, "err", err)
 		return err
 	}
 
 	return nil
 }
 
-func getCommitHashsForPr(dir string, pr gh.PR) (*set.Set[string], error) {
+func getCommitHashsForPr(ctx context.Context, dir string, pr gh.PR) (*set.Set[string], error) {
 
 	slog.Default().Debug("processing pr", "pr number", pr.Number, "base ref", pr.BaseRefOid, "head ref", pr.HeadRefOid)
-	newCommits, err := getRevList(dir, fmt.Sprintf("%s..%s", pr.BaseRefOid, pr.HeadRefOid))
+	newCommits, err := getRevList(ctx, dir, pr.BaseRefOid, pr.HeadRefOid)
 	if err != nil {
 		return nil, err
 	}
 	commitSet := set.From(newCommits)
-	commitSet.Insert(pr.MergeCommit.Oid)
+	if validSha(pr.MergeCommit.Oid) {
+		commitSet.Insert(pr.MergeCommit.Oid)
+	}
+	if validSha(pr.SquashCommitOid) {
+		commitSet.Insert(pr.SquashCommitOid)
+	}
+	for _, r := range pr.Revisions {
+		commits, err := getRevList(ctx, dir, r.BaseRefOid, r.HeadRefOid)
+		if err != nil {
+			// earlier revisions might have been garbage collected by the forge
+			slog.Default().Debug("Get commits of revision failed", "pr number", pr.Number, "head ref", r.HeadRefOid, "err", err)
+			continue
+		}
+		commitSet.InsertSlice(commits)
+	}
 
 	return commitSet, nil
 }
 
-// getRevList executes the git rev-list command and returns commit hashes.
-func getRevList(repoPath, arg string) ([]string, error) {
-	cmd := exec.Command("git", "rev-list", arg)
-	cmd.Dir = repoPath
-	out, err := cmd.Output()
+// getRevList executes the git rev-list command and returns the hashes of
+// the commits reachable from head, but not from base.
+func getRevList(ctx context.Context, repoPath, base, head string) ([]string, error) {
+	if !validSha(base) || !validSha(head) {
+		return nil, fmt.Errorf("invalid commit range %q..%q", base, head)
+	}
+	out, err := newGitCmd(ctx, repoPath, shortTimeout, "rev-list", head, "^"+base, "--").output()
 	if err != nil {
 		return nil, err
 	}
@@ -91,44 +136,210 @@ func getRevList(repoPath, arg string) ([]string, error) {
 	return commits, nil
 }
 
-func CloneRepo(url, dir string) error {
-	cmd := exec.Command("git", "clone", "--bare", url, dir)
-	_, err := cmd.Output()
+func CloneRepo(ctx context.Context, url, dir string, auth Auth) error {
+	cmd := newGitCmd(ctx, "", remoteTimeout, "clone", "--bare", "--", url, dir)
+	if err := cmd.withAuth(auth); err != nil {
+		return err
+	}
+	return cmd.run()
+}
+
+// FetchRepo updates all branches of the existing repository in dir from origin.
+// Refs of PRs fetched by earlier runs are kept, as they don't change after the merge.
+// The branches are overwritten, so only bare repositories, e.g., clones and mirrors
+// created for the analysis, are updated. The local branches of repositories with a
+// working tree could contain unpushed work.
+func FetchRepo(ctx context.Context, dir string, auth Auth) error {
+	out, err := newGitCmd(ctx, dir, shortTimeout, "rev-parse", "--is-bare-repository").output()
 	if err != nil {
 		return err
 	}
+	if strings.TrimSpace(string(out)) != "true" {
+		return fmt.Errorf("%s is not a bare repository, whose branches can be overwritten from origin", dir)
+	}
 
+	cmd := newGitCmd(ctx, dir, remoteTimeout, "fetch", "origin", "--", "+refs/heads/*:refs/heads/*")
+	if err := cmd.withAuth(auth); err != nil {
+		return err
+	}
+	if err := cmd.run(); err != nil {
+		slog.Default().Error("Git fetch failed", "target dir", dir, "err", err)
+		return err
+	}
 	return nil
 }
 
-func GetCommitsFromHashs(repoPath string, hashs []string) ([]io.Commit, error) {
-	return getCommit(show, repoPath, hashs)
+// GetRemoteURL returns the URL of the remote origin of the repository in dir.
+func GetRemoteURL(ctx context.Context, dir string) (string, error) {
+	out, err := newGitCmd(ctx, dir, shortTimeout, "config", "--get", "remote.origin.url").output()
+	if err != nil {
+		return "", fmt.Errorf("repository %s has no remote origin: %w", dir, err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// IsRepo reports whether dir is the root of a bare repository or of the working tree of a repository.
+func IsRepo(ctx context.Context, dir string) bool {
+	out, err := newGitCmd(ctx, dir, shortTimeout, "rev-parse", "--absolute-git-dir").output()
+	if err != nil {
+		return false
+	}
+	gitDir, err := filepath.EvalSymlinks(strings.TrimSpace(string(out)))
+	if err != nil {
+		return false
+	}
+	root, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return false
+	}
+	root, err = filepath.Abs(root)
+	if err != nil {
+		return false
+	}
+	// dir might be part of another repository, e.g., if the clone target is inside a repository
+	return gitDir == root || gitDir == filepath.Join(root, ".git")
+}
+
+func GetCommitsFromHashs(ctx context.Context, repoPath string, hashs []string) ([]io.Commit, error) {
+	for _, h := range hashs {
+		if !validSha(h) {
+			return nil, fmt.Errorf("invalid commit hash %q", h)
+		}
+	}
+	return getCommit(ctx, show, repoPath, hashs, nil)
 }
 
-func GetCommitsFromBranch(repoPath, branch string) ([]io.Commit, error) {
-	return getCommit(log, repoPath, []string{branch})
+// GetCommitsFromBranch returns the commits of the branch. If exclude is not empty,
+// the commits reachable from the commit with the hash exclude are omitted.
+// Their signatures are verified with keyring, which sets their Verdict.
+func GetCommitsFromBranch(ctx context.Context, repoPath, branch, exclude string, keyring *Keyring) ([]io.Commit, error) {
+	if !validBranch(branch) {
+		return nil, fmt.Errorf("invalid branch name %q", branch)
+	}
+	// the full ref takes precedence over tags with the same name
+	revs := []string{"refs/heads/" + branch}
+	if exclude != "" {
+		if !validSha(exclude) {
+			return nil, fmt.Errorf("invalid commit hash %q", exclude)
+		}
+		revs = append(revs, "^"+exclude)
+	}
+	commits, err := getCommit(ctx, log, repoPath, revs, keyring)
+	if err != nil {
+		return nil, err
+	}
+	for i := range commits {
+		commits[i].Verdict = keyring.verdict(commits[i])
+	}
+	return commits, nil
 }
 
-func GetPatchIdAndUnsignedCommits(repoPath, branch string, cache *PatchIdCache) (*map[string]*io.Commit, *[]io.Commit, error) {
-	allCommits, err := GetCommitsFromBranch(repoPath, branch)
+// GetHead returns the hash of the newest commit of the branch.
+func GetHead(ctx context.Context, repoPath, branch string) (string, error) {
+	if !validBranch(branch) {
+		return "", fmt.Errorf("invalid branch name %q", branch)
+	}
+	out, err := newGitCmd(ctx, repoPath, shortTimeout, "rev-parse", "--verify", "--quiet", "refs/heads/"+branch+"^{commit}").output()
 	if err != nil {
-		return nil, nil, err
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// ResolveCommit returns the hash of the commit the tag or full commit hash rev refers to.
+func ResolveCommit(ctx context.Context, repoPath, rev string) (string, error) {
+	var ref string
+	switch {
+	case validSha(rev):
+		ref = rev
+	case rev != "" && !strings.HasPrefix(rev, "-") && validRef("refs/tags/"+rev):
+		ref = "refs/tags/" + rev
+	default:
+		return "", fmt.Errorf("invalid tag or commit hash %q", rev)
 	}
+	out, err := newGitCmd(ctx, repoPath, shortTimeout, "rev-parse", "--verify", "--quiet", ref+"^{commit}").output()
+	if err != nil {
+		return "", fmt.Errorf("unknown tag or commit %q: %w", rev, err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// GetCommitsAfter returns the hashes of the commits of the branch which aren't reachable
+// from the parents of the commit with the hash commit, i.e., the commit itself and all
+// commits merged after it.
+func GetCommitsAfter(ctx context.Context, repoPath, branch, commit string) (*set.Set[string], error) {
+	if !validBranch(branch) {
+		return nil, fmt.Errorf("invalid branch name %q", branch)
+	}
+	if !validSha(commit) {
+		return nil, fmt.Errorf("invalid commit hash %q", commit)
+	}
+	out, err := newGitCmd(ctx, repoPath, longTimeout, "rev-list", "refs/heads/"+branch, "--not", commit+"^@", "--").output()
+	if err != nil {
+		return nil, err
+	}
+	return set.From(strings.Fields(string(out))), nil
+}
+
+// GetCommitsChangingPaths returns the hashes of the commits of the branch changing files
+// matching the glob patterns, e.g., ".github/workflows/**". Patterns with pathspec magic,
+// e.g., ":(icase)go.mod", are passed unchanged. If exclude is not empty, the commits
+// reachable from the commit with the hash exclude are omitted.
+func GetCommitsChangingPaths(ctx context.Context, repoPath, branch, exclude string, patterns []string) (*set.Set[string], error) {
+	if !validBranch(branch) {
+		return nil, fmt.Errorf("invalid branch name %q", branch)
+	}
+	// without --full-history commits of merged branches are skipped if the merge didn't change the files
+	args := []string{"rev-list", "--full-history", "refs/heads/" + branch}
+	if exclude != "" {
+		if !validSha(exclude) {
+			return nil, fmt.Errorf("invalid commit hash %q", exclude)
+		}
+		args = append(args, "^"+exclude)
+	}
+	args = append(args, "--")
+	for _, p := range patterns {
+		if !strings.HasPrefix(p, ":") {
+			p = ":(glob)" + p
+		}
+		args = append(args, p)
+	}
+	out, err := newGitCmd(ctx, repoPath, longTimeout, args...).output()
+	if err != nil {
+		return nil, err
+	}
+	return set.From(strings.Fields(string(out))), nil
+}
+
+// GetPatchIdAndUnsignedCommits maps the patch ids of the commits of the branch to the commits
+// and returns the commits without a valid signature according to their Verdict.
+func GetPatchIdAndUnsignedCommits(ctx context.Context, repoPath, branch string, allCommits []io.Commit, cache *PatchIdCache) (*map[string]*io.Commit, *[]io.Commit, error) {
 	numberCommits := len(allCommits)
 	slog.Default().Info("Number all commits", branch, numberCommits)
 
 	patchIdToCommit := make(map[string]*io.Commit, len(allCommits))
 	unsignedCommits := make([]io.Commit, 0, len(allCommits)/3)
 
+	hashes := make([]string, len(allCommits))
+	for i, c := range allCommits {
+		hashes[i] = c.GitOID
+	}
+	patchIds, err := cache.GetOrCreatePatchIds(ctx, repoPath, hashes)
+	if err != nil {
+		return nil, nil, err
+	}
+
 	for i := range allCommits {
 		c := &allCommits[i]
-		pi, err := cache.GetOrCreatePatchId(repoPath, c.GitOID)
-		if err != nil || pi == "" {
-			slog.Default().Debug("Get patch id failed or is empty. Setting patch id to original commit id", "err", err)
+		pi := patchIds[c.GitOID]
+		if pi == "" {
+			slog.Default().Debug("Patch id is empty. Setting patch id to original commit id", "commit", c.GitOID)
 			pi = c.GitOID
 		}
 		patchIdToCommit[pi] = c
-		if c.Signed == "N" || c.Signed == "B" {
+		// signatures made with keys that expired since then are still valid
+		switch c.Verdict {
+		case io.VerdictMissing, io.VerdictInvalid, io.VerdictRevoked:
 			unsignedCommits = append(unsignedCommits, *c)
 		}
 	}
@@ -143,29 +354,36 @@ const (
 	log  GitCmd = "log"
 )
 
-type DELIMITER string
-
-const (
-	lineBreak DELIMITER = "<<<CUSTOM_LINEBREAK>>>"
-	value     DELIMITER = "<<<VALUE>>>"
-)
-
-func getCommit(gitCmd GitCmd, repoPath string, input []string) ([]io.Commit, error) {
-	format := "--pretty=tformat:%H" + value + "%f %b" + value + "%ci" + value + "%G?" + value + lineBreak
-	args := append([]string{string(gitCmd), "--no-patch", "--expand-tabs", string(format)}, input...)
+// commitFields are the placeholders of the pretty format of getCommit. The fields
+// are separated by NUL, which can't be part of commit messages.
+// %aI and %cI are the strict ISO 8601 format of the dates, which is parsed as RFC 3339.
+// The trailers are unfolded into single lines, with the key and value separated by
+// the unit separator 0x1f and the trailers by the record separator 0x1e.
+var commitFields = []string{
+	"%H", "%P",
+	"%an", "%ae", "%aI",
+	"%cn", "%ce", "%cI",
+	"%G?", "%GK", "%GS",
+	"%s", "%(trailers:only,unfold,key_value_separator=%x1f,separator=%x1e)", "%B",
+}
 
-	cmd := exec.Command("git", args...)
-	cmd.Dir = repoPath
-	out, err := cmd.Output()
+// getCommit returns the commits of the revisions, which must have been validated.
+// Signatures are verified with keyring, or with the keys of the user if it is nil.
+func getCommit(ctx context.Context, gitCmd GitCmd, repoPath string, input []string, keyring *Keyring) ([]io.Commit, error) {
+	// with -z the commits are separated by NUL as well
+	format := "--pretty=tformat:" + strings.Join(commitFields, "%x00")
+	args := append([]string{string(gitCmd), "--no-patch", "-z", format}, input...)
+	args = append(args, "--")
+
+	cmd := newGitCmd(ctx, repoPath, longTimeout, args...)
+	cmd.withKeyring(keyring)
+	out, err := cmd.output()
 	if err != nil {
 		slog.Default().Error("error during get commit", "err", err, "input", input)
 		return nil, err
 	}
-	str := removeControls(string(out))
-	// Split by newline to get each commit hash.
-	rawCommits := strings.Split(strings.TrimSuffix(str, string(lineBreak)), string(lineBreak))
 
-	return parseCommits(rawCommits), nil
+	return parseCommits(string(out))
 }
 
 func removeControls(s string) string {
@@ -177,24 +395,88 @@ func removeControls(s string) string {
 	}, s)
 }
 
-func parseCommits(rawCommits []string) []io.Commit {
-	commits := make([]io.Commit, 0, len(rawCommits))
-	for _, rc := range rawCommits {
-		split := strings.Split(rc, string(value))
+func parseCommits(out string) ([]io.Commit, error) {
+	if out == "" {
+		return []io.Commit{}, nil
+	}
+	fields := strings.Split(strings.TrimSuffix(out, "\x00"), "\x00")
+	if len(fields)%len(commitFields) != 0 {
+		return nil, fmt.Errorf("unexpected number of commit fields %d", len(fields))
+	}
 
-		if len(split) < 4 {
-			slog.Default().Warn("Commit parsing failed. Split length to short.", "split", split)
-			continue
+	commits := make([]io.Commit, 0, len(fields)/len(commitFields))
+	for i := 0; i < len(fields); i += len(commitFields) {
+		f := fields[i : i+len(commitFields)]
+		if !validSha(f[0]) {
+			return nil, fmt.Errorf("invalid commit hash %q", f[0])
 		}
 
 		c := io.Commit{
-			GitOID:  split[0],
-			Message: split[1],
-			Date:    split[2],
-			Signed:  split[3],
+			GitOID:     f[0],
+			Parents:    strings.Fields(f[1]),
+			Author:     parsePerson(f[0], f[2], f[3], f[4]),
+			Committer:  parsePerson(f[0], f[5], f[6], f[7]),
+			Signed:     f[8],
+			SigningKey: removeControls(f[9]),
+			Signer:     removeControls(f[10]),
+			Subject:    removeControls(f[11]),
+			Trailers:   parseTrailers(f[12]),
+			Message:    strings.TrimSuffix(f[13], "\n"),
 		}
+		c.Date = c.Committer.Date
 		commits = append(commits, c)
 	}
 
-	return commits
+	return commits, nil
+}
+
+func parsePerson(commit, name, email, date string) io.Person {
+	p := io.Person{
+		Name:  removeControls(name),
+		Email: removeControls(email),
+	}
+	var err error
+	p.Date, err = time.Parse(time.RFC3339, date)
+	if err != nil {
+		slog.Default().Warn("Commit date parsing failed.", "commit", commit, "date", date, "err", err)
+	}
+	return p
+}
+
+func parseTrailers(s string) []io.Trailer {
+	if s == "" {
+		return nil
+	}
+	lines := strings.Split(s, "\x1e")
+	trailers := make([]io.Trailer, 0, len(lines))
+	for _, l := range lines {
+		key, value, ok := strings.Cut(l, "\x1f")
+		if !ok {
+			continue
+		}
+		trailers = append(trailers, io.Trailer{Key: removeControls(key), Value: removeControls(value)})
+	}
+	return trailers
+}
+
+// GetDefaultBranch returns the branch HEAD of the repository points to.
+func GetDefaultBranch(ctx context.Context, repoPath string) (string, error) {
+	// the short name is ambiguous, if a tag with the same name exists
+	out, err := newGitCmd(ctx, repoPath, shortTimeout, "symbolic-ref", "HEAD").output()
+	if err != nil {
+		return "", err
+	}
+	branch, ok := strings.CutPrefix(strings.TrimSpace(string(out)), "refs/heads/")
+	if !ok || !validBranch(branch) {
+		return "", fmt.Errorf("invalid branch name %q", branch)
+	}
+	return branch, nil
+}
+
+// IsAncestor reports whether the commit with the hash ancestor is an ancestor of or equal to the commit with the hash commit.
+func IsAncestor(ctx context.Context, repoPath, ancestor, commit string) bool {
+	if !validSha(ancestor) || !validSha(commit) {
+		return false
+	}
+	return newGitCmd(ctx, repoPath, shortTimeout, "merge-base", "--is-ancestor", ancestor, commit, "--").run() == nil
 }
@@ -0,0 +1,148 @@
+package vcs
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"project-integrity-calculator/internal/gittest"
+	"project-integrity-calculator/internal/io"
+)
+
+// commitFixtureRepo creates a repository with commits whose messages and parents
+// cover the cases parseCommits treats specially.
+func commitFixtureRepo(t *testing.T) *gittest.Repo {
+	t.Helper()
+	r := gittest.New(t)
+
+	r.WriteFile("a.txt", "a\n")
+	r.Commit("root commit")
+
+	r.Commit("")
+
+	r.Commit("add trailers\n\nBody line.\n\n" +
+		"Signed-off-by: Alice <alice@example.com>\n" +
+		"Co-authored-by: Bob\n <bob@example.com>\n" +
+		"Reviewed-by: Carol <carol@example.com>\n")
+
+	r.Git("checkout", "-q", "-b", "side")
+	r.WriteFile("side.txt", "side\n")
+	r.Commit("side")
+	r.Git("checkout", "-q", "main")
+	r.Git("merge", "-q", "--no-ff", "side", "-m", "Merge branch 'side'")
+
+	return r
+}
+
+func TestGetCommitsFromBranch(t *testing.T) {
+	r := commitFixtureRepo(t)
+	commits, err := GetCommitsFromBranch(context.Background(), r.Dir, "main", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bySubject := make(map[string]io.Commit, len(commits))
+	for _, c := range commits {
+		if c.Author.Name != "Test" || c.Author.Email != "test@example.com" || c.Committer.Name != "Test" {
+			t.Errorf("commit %q has author %+v and committer %+v", c.Subject, c.Author, c.Committer)
+		}
+		if c.Date.IsZero() || !c.Date.Equal(c.Committer.Date) {
+			t.Errorf("commit %q has date %v, committer date %v", c.Subject, c.Date, c.Committer.Date)
+		}
+		if c.Signed != "N" {
+			t.Errorf("commit %q has signature status %q, want N", c.Subject, c.Signed)
+		}
+		bySubject[c.Subject] = c
+	}
+	if len(commits) != 5 || len(bySubject) != 5 {
+		t.Fatalf("got %d commits with %d subjects, want 5", len(commits), len(bySubject))
+	}
+
+	if c := bySubject["root commit"]; len(c.Parents) != 0 || c.Message != "root commit" || c.Trailers != nil {
+		t.Errorf("root commit = %+v", c)
+	}
+
+	if c := bySubject[""]; len(c.Parents) != 1 || c.Message != "" || c.Trailers != nil {
+		t.Errorf("commit with empty message = %+v", c)
+	}
+
+	trailers := bySubject["add trailers"]
+	wantTrailers := []io.Trailer{
+		{Key: "Signed-off-by", Value: "Alice <alice@example.com>"},
+		{Key: "Co-authored-by", Value: "Bob <bob@example.com>"},
+		{Key: "Reviewed-by", Value: "Carol <carol@example.com>"},
+	}
+	if !reflect.DeepEqual(trailers.Trailers, wantTrailers) {
+		t.Errorf("trailers = %+v, want %+v", trailers.Trailers, wantTrailers)
+	}
+	if !strings.HasPrefix(trailers.Message, "add trailers\n\nBody line.\n\n") || !strings.HasSuffix(trailers.Message, "Reviewed-by: Carol <carol@example.com>") {
+		t.Errorf("message = %q", trailers.Message)
+	}
+
+	merge := bySubject["Merge branch 'side'"]
+	if len(merge.Parents) != 2 || merge.Parents[1] != bySubject["side"].GitOID || merge.Parents[0] != trailers.GitOID {
+		t.Errorf("merge has parents %v, want [%s %s]", merge.Parents, trailers.GitOID, bySubject["side"].GitOID)
+	}
+}
+
+func TestParseCommits(t *testing.T) {
+	hash := "0123456789abcdef0123456789abcdef01234567"
+	fields := func(hash, date string) string {
+		f := []string{hash, "", "Alice", "alice@example.com", date, "Bob", "bob@example.com", date,
+			"N", "", "", "subject\x07", "", "subject\x07\n"}
+		return strings.Join(f, "\x00") + "\x00"
+	}
+
+	commits, err := parseCommits("")
+	if err != nil || len(commits) != 0 {
+		t.Errorf("parseCommits(\"\") = %v, %v, want no commits", commits, err)
+	}
+
+	commits, err = parseCommits(fields(hash, "2024-03-01T12:30:00+02:00") + fields(hash, "invalid"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(commits) != 2 {
+		t.Fatalf("got %d commits, want 2", len(commits))
+	}
+	if commits[0].Subject != "subject" || commits[0].Message != "subject\x07" {
+		t.Errorf("subject = %q, message = %q", commits[0].Subject, commits[0].Message)
+	}
+	if commits[0].Date.IsZero() || commits[0].Author.Email != "alice@example.com" || commits[0].Committer.Name != "Bob" {
+		t.Errorf("commit = %+v", commits[0])
+	}
+	// invalid dates are logged and left zero
+	if !commits[1].Date.IsZero() {
+		t.Errorf("date = %v, want zero", commits[1].Date)
+	}
+
+	if _, err := parseCommits(strings.TrimSuffix(fields(hash, ""), "\x00") + "\x00extra\x00"); err == nil {
+		t.Errorf("parseCommits succeeded with unexpected number of fields")
+	}
+	if _, err := parseCommits(fields("--output=x", "")); err == nil {
+		t.Errorf("parseCommits succeeded with invalid hash")
+	}
+}
+
+func TestParseTrailers(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want []io.Trailer
+	}{
+		{"no trailers", "", nil},
+		{"single", "Signed-off-by\x1fAlice", []io.Trailer{{Key: "Signed-off-by", Value: "Alice"}}},
+		{"multiple", "A\x1f1\x1eB\x1f2", []io.Trailer{{Key: "A", Value: "1"}, {Key: "B", Value: "2"}}},
+		{"empty value", "A\x1f", []io.Trailer{{Key: "A", Value: ""}}},
+		{"without separator", "A\x1f1\x1einvalid", []io.Trailer{{Key: "A", Value: "1"}}},
+		{"control characters", "A\x1b\x1f1\x07", []io.Trailer{{Key: "A", Value: "1"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseTrailers(tt.in); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseTrailers(%q) = %+v, want %+v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
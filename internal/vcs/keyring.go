@@ -0,0 +1,219 @@
+package vcs
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"project-integrity-calculator/internal/gh"
+	"project-integrity-calculator/internal/io"
+
+	"github.com/hashicorp/go-set/v3"
+)
+
+// Keyring contains the keys commit signatures are verified with. Git verifies the
+// signatures with it instead of the GnuPG and SSH setup of the user, so the verification
+// is reproducible on any machine. The keys of the OpenPGP keyring and the SSH allowed signers
+// file are trusted. The known keys of the contributors only prove that signatures are valid.
+type Keyring struct {
+	dir            string
+	allowedSigners string
+	// fingerprints of the known SSH keys of the contributors
+	knownSSHKeys *set.Set[string]
+}
+
+// NewKeyring creates a keyring in a temporary directory, which is removed by Close.
+// gpgKeyring is an OpenPGP keyring and allowedSigners an SSH allowed signers file with
+// the trusted keys. Both are optional, without them no key is trusted. known contains
+// the public keys of the contributors and may be nil.
+func NewKeyring(ctx context.Context, gpgKeyring, allowedSigners string, known *gh.SigningKeys) (*Keyring, error) {
+	dir, err := os.MkdirTemp("", "keyring")
+	if err != nil {
+		return nil, err
+	}
+	k := &Keyring{
+		dir:          dir,
+		knownSSHKeys: set.New[string](0),
+	}
+	if err := k.init(ctx, gpgKeyring, allowedSigners, known); err != nil {
+		_ = k.Close()
+		return nil, err
+	}
+	return k, nil
+}
+
+func (k *Keyring) init(ctx context.Context, gpgKeyring, allowedSigners string, known *gh.SigningKeys) error {
+	if err := os.Mkdir(k.gnupgHome(), 0o700); err != nil {
+		return err
+	}
+	// with the direct trust model only keys with ownertrust are valid, not keys certified by them.
+	// gpg must not start an agent, which would outlive the keyring.
+	if err := os.WriteFile(filepath.Join(k.gnupgHome(), "gpg.conf"), []byte("trust-model direct\nno-autostart\n"), 0o600); err != nil {
+		return err
+	}
+
+	if gpgKeyring != "" {
+		if _, err := k.gpg(ctx, nil, "--import", "--", gpgKeyring); err != nil {
+			return err
+		}
+		if err := k.trustAllKeys(ctx); err != nil {
+			return err
+		}
+	}
+
+	if allowedSigners != "" {
+		path, err := filepath.Abs(allowedSigners)
+		if err != nil {
+			return err
+		}
+		if _, err := os.Stat(path); err != nil {
+			return err
+		}
+		k.allowedSigners = path
+	} else {
+		// git requires an allowed signers file to verify SSH signatures
+		k.allowedSigners = filepath.Join(k.dir, "allowed_signers")
+		if err := os.WriteFile(k.allowedSigners, nil, 0o600); err != nil {
+			return err
+		}
+	}
+
+	if known == nil {
+		return nil
+	}
+	// keys are provided by the forge, so invalid keys are skipped
+	for _, key := range known.GPG {
+		if _, err := k.gpg(ctx, strings.NewReader(key), "--import"); err != nil {
+			slog.Default().Warn("Ignoring invalid GPG key of contributor", "err", err)
+		}
+	}
+	for _, key := range known.SSH {
+		fingerprint, ok := sshFingerprint(key)
+		if !ok {
+			slog.Default().Warn("Ignoring invalid SSH key of contributor", "key", key)
+			continue
+		}
+		k.knownSSHKeys.Insert(fingerprint)
+	}
+	return nil
+}
+
+// trustAllKeys sets the ownertrust of all imported keys to ultimate.
+func (k *Keyring) trustAllKeys(ctx context.Context) error {
+	out, err := k.gpg(ctx, nil, "--with-colons", "--list-keys")
+	if err != nil {
+		return err
+	}
+	var ownertrust strings.Builder
+	primary := false
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		fields := strings.Split(scanner.Text(), ":")
+		switch fields[0] {
+		case "pub":
+			primary = true
+		case "sub":
+			primary = false
+		case "fpr":
+			// the fingerprint follows the key it belongs to
+			if primary && len(fields) > 9 {
+				ownertrust.WriteString(fields[9] + ":6:\n")
+				primary = false
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	_, err = k.gpg(ctx, strings.NewReader(ownertrust.String()), "--import-ownertrust")
+	return err
+}
+
+// gpg runs gpg with the keyring as home directory.
+func (k *Keyring) gpg(ctx context.Context, stdin *strings.Reader, args ...string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, shortTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "gpg", append([]string{"--batch", "--no-tty", "--homedir", k.gnupgHome()}, args...)...)
+	cmd.Env = cleanEnv()
+	if stdin != nil {
+		cmd.Stdin = stdin
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("gpg %s failed: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
+
+func (k *Keyring) gnupgHome() string {
+	return filepath.Join(k.dir, "gnupg")
+}
+
+// config returns the git config verifying signatures with the keyring. It overrides
+// the config of the repository, which could lower the required trust.
+func (k *Keyring) config() [][2]string {
+	return [][2]string{
+		{"gpg.minTrustLevel", "fully"},
+		{"gpg.ssh.allowedSignersFile", k.allowedSigners},
+	}
+}
+
+func (k *Keyring) env() []string {
+	return []string{"GNUPGHOME=" + k.gnupgHome()}
+}
+
+// verdict returns the verdict of the signature of c, which has been verified with the keyring.
+func (k *Keyring) verdict(c io.Commit) io.Verdict {
+	switch c.Signed {
+	case "G":
+		return io.VerdictTrusted
+	case "U":
+		// git reports valid SSH signatures of keys missing in the allowed signers file with "U".
+		// Anyone could have made them, unless the key is known to belong to a contributor.
+		if strings.HasPrefix(c.SigningKey, "SHA256:") && (k == nil || !k.knownSSHKeys.Contains(c.SigningKey)) {
+			return io.VerdictUnverifiable
+		}
+		return io.VerdictUntrusted
+	case "E":
+		// GPG signatures of keys missing in the keyring can't be checked
+		return io.VerdictUnverifiable
+	case "X", "Y":
+		return io.VerdictExpired
+	case "R":
+		return io.VerdictRevoked
+	case "N":
+		return io.VerdictMissing
+	default:
+		return io.VerdictInvalid
+	}
+}
+
+// Close removes the keyring.
+func (k *Keyring) Close() error {
+	return os.RemoveAll(k.dir)
+}
+
+// sshFingerprint returns the SHA256 fingerprint of a public key in the
+// authorized_keys format, e.g., "ssh-ed25519 AAAA... comment".
+func sshFingerprint(key string) (string, bool) {
+	fields := strings.Fields(key)
+	if len(fields) < 2 {
+		return "", false
+	}
+	blob, err := base64.StdEncoding.DecodeString(fields[1])
+	if err != nil {
+		return "", false
+	}
+	sum := sha256.Sum256(blob)
+	return "SHA256:" + base64.RawStdEncoding.EncodeToString(sum[:]), true
+}
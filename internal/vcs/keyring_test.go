@@ -0,0 +1,84 @@
+package vcs
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"project-integrity-calculator/internal/io"
+
+	"github.com/hashicorp/go-set/v3"
+)
+
+func TestVerdict(t *testing.T) {
+	knownKey := "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIGaNBs6OEtMeCqRpVa2cm/fvlMqEPo8Ra6kpr0jBfWbA alice@example.com"
+	known, ok := sshFingerprint(knownKey)
+	if !ok {
+		t.Fatal("invalid SSH key")
+	}
+	keyring := &Keyring{knownSSHKeys: set.From([]string{known})}
+	unknown := "SHA256:47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU"
+	gpgKey := "4AEE18F83AFDEB23"
+
+	tests := []struct {
+		name    string
+		keyring *Keyring
+		signed  string
+		key     string
+		want    io.Verdict
+	}{
+		{"good signature", keyring, "G", gpgKey, io.VerdictTrusted},
+		{"good SSH signature", keyring, "G", unknown, io.VerdictTrusted},
+		{"bad signature", keyring, "B", gpgKey, io.VerdictInvalid},
+		{"GPG key without trust", keyring, "U", gpgKey, io.VerdictUntrusted},
+		{"known SSH key", keyring, "U", known, io.VerdictUntrusted},
+		{"unknown SSH key", keyring, "U", unknown, io.VerdictUnverifiable},
+		{"unknown SSH key without keyring", nil, "U", unknown, io.VerdictUnverifiable},
+		{"expired signature", keyring, "X", gpgKey, io.VerdictExpired},
+		{"expired key", keyring, "Y", gpgKey, io.VerdictExpired},
+		{"revoked key", keyring, "R", gpgKey, io.VerdictRevoked},
+		{"unknown GPG key", keyring, "E", gpgKey, io.VerdictUnverifiable},
+		{"unknown GPG key without keyring", nil, "E", gpgKey, io.VerdictUnverifiable},
+		{"no signature", keyring, "N", "", io.VerdictMissing},
+		{"unexpected status", keyring, "?", "", io.VerdictInvalid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := io.Commit{Signed: tt.signed, SigningKey: tt.key}
+			if got := tt.keyring.verdict(c); got != tt.want {
+				t.Errorf("verdict(%q, %q) = %q, want %q", tt.signed, tt.key, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSSHFingerprintMatchesSSHKeygen(t *testing.T) {
+	if _, err := exec.LookPath("ssh-keygen"); err != nil {
+		t.Skip("ssh-keygen is not installed")
+	}
+	key := filepath.Join(t.TempDir(), "id_ed25519")
+	if out, err := exec.Command("ssh-keygen", "-q", "-t", "ed25519", "-N", "", "-C", "alice@example.com", "-f", key).CombinedOutput(); err != nil {
+		t.Fatalf("ssh-keygen failed: %s: %s", err, out)
+	}
+	public, err := os.ReadFile(key + ".pub")
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := exec.Command("ssh-keygen", "-l", "-E", "sha256", "-f", key+".pub").Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the output is "<bits> <fingerprint> <comment> (<type>)"
+	want := strings.Fields(string(out))[1]
+
+	if got, ok := sshFingerprint(string(public)); !ok || got != want {
+		t.Errorf("sshFingerprint() = %q, %v, want %q", got, ok, want)
+	}
+	for _, invalid := range []string{"", "ssh-ed25519", "ssh-ed25519 not-base64!"} {
+		if got, ok := sshFingerprint(invalid); ok {
+			t.Errorf("sshFingerprint(%q) = %q, want invalid key", invalid, got)
+		}
+	}
+}
@@ -1,74 +1,308 @@
 package vcs
 
 import (
+	"bufio"
+	"container/list"
+	"context"
+	"errors"
 	"fmt"
+	"io/fs"
 	"log/slog"
-	"os/exec"
+	"os"
+	"path/filepath"
 	"strings"
 	"sync"
 )
 
+// PatchIdCache maps commit hashes to their stable patch ids. Patch ids of
+// a commit never change, so the cache can be shared between repositories
+// and, if it is backed by a file, between runs.
+// If more than maxSize entries are cached, the least recently used are evicted.
 type PatchIdCache struct {
 	maxSize int
-	cache   map[string]string
-	rw      sync.RWMutex
+	entries map[string]*list.Element
+	// most recently used entries are at the front
+	lru *list.List
+	mu  sync.Mutex
+
+	// optional file to which new entries are appended
+	path string
+	file *os.File
+
+	hits, misses int
 }
 
+type patchIdEntry struct {
+	hash, patchId string
+}
+
+// NewPatchIdCache creates a cache which only lives in memory.
 func NewPatchIdCache(size int) *PatchIdCache {
-	c := PatchIdCache{
-		cache:   make(map[string]string),
+	return &PatchIdCache{
 		maxSize: size,
-		rw:      sync.RWMutex{},
+		entries: make(map[string]*list.Element),
+		lru:     list.New(),
+	}
+}
+
+// OpenPatchIdCache creates a cache backed by the file at path, which is created if it
+// doesn't exist. Each line of the file contains a commit hash and its patch id.
+// New entries are appended immediately, so they survive crashes. Close compacts the
+// file to the entries currently cached, removing evicted and duplicate entries.
+func OpenPatchIdCache(path string, size int) (*PatchIdCache, error) {
+	c := NewPatchIdCache(size)
+	c.path = path
+
+	if err := c.load(); err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
 	}
-	return &c
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	c.file = file
+
+	slog.Default().Info("Opened patch id cache", "file", path, "entries", len(c.entries))
+	return c, nil
 }
 
+func (c *PatchIdCache) load() error {
+	file, err := os.Open(c.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := file.Close(); err != nil {
+			slog.Default().Warn("Failed to close patch id cache", "file", c.path, "error", err)
+		}
+	}()
+
+	// entries are appended, so later lines are more recent
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		hash, patchId, ok := strings.Cut(scanner.Text(), " ")
+		if !ok || hash == "" {
+			continue
+		}
+		c.set(hash, patchId)
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read patch id cache %s: %w", c.path, err)
+	}
+	return nil
+}
+
+// Add caches the patch id of the commit with the given hash.
 func (c *PatchIdCache) Add(key string, value string) {
-	c.rw.Lock()
-	defer c.rw.Unlock()
-
-	if len(c.cache) > c.maxSize {
-		slog.Default().Info("Max cache size reached")
-		deleteTarget := len(c.cache) / 10
-		slog.Default().Info("Removing entries", "to remove", deleteTarget)
-		deleteCounter := 0
-		for h := range c.cache {
-			delete(c.cache, h)
-			deleteCounter++
-			if deleteCounter == deleteTarget {
-				break
-			}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.set(key, value)
+	if c.file != nil {
+		if _, err := fmt.Fprintf(c.file, "%s %s\n", key, value); err != nil {
+			slog.Default().Warn("Failed to persist patch id", "file", c.path, "error", err)
 		}
 	}
-	c.cache[key] = value
 }
 
-func (c *PatchIdCache) get(key string) *string {
-	c.rw.RLock()
-	defer c.rw.RUnlock()
-	r := c.cache[key]
-	if r == "" {
-		return nil
+// set inserts or updates an entry and evicts the least recently used entries if
+// the cache is full. The caller must hold the lock, if needed.
+func (c *PatchIdCache) set(key, value string) {
+	if e, ok := c.entries[key]; ok {
+		e.Value.(*patchIdEntry).patchId = value
+		c.lru.MoveToFront(e)
+		return
+	}
+
+	c.entries[key] = c.lru.PushFront(&patchIdEntry{hash: key, patchId: value})
+	for len(c.entries) > c.maxSize {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*patchIdEntry).hash)
 	}
-	return &r
 }
 
-func (c *PatchIdCache) GetOrCreatePatchId(dir string, hash string) (string, error) {
+func (c *PatchIdCache) get(key string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 
-	cacheResult := c.get(hash)
-	if cacheResult != nil {
-		return *cacheResult, nil
+	e, ok := c.entries[key]
+	if !ok {
+		c.misses++
+		return "", false
 	}
+	c.hits++
+	c.lru.MoveToFront(e)
+	return e.Value.(*patchIdEntry).patchId, true
+}
 
-	cmd := exec.Command("sh", "-c", fmt.Sprintf("git show %s | git patch-id --stable", hash))
-	cmd.Dir = dir
-	out, err := cmd.Output()
+// GetOrCreatePatchId returns the stable patch id of the commit with the given hash.
+// The patch id is empty for commits without changes, e.g., most merge commits.
+func (c *PatchIdCache) GetOrCreatePatchId(ctx context.Context, dir string, hash string) (string, error) {
+	patchIds, err := c.GetOrCreatePatchIds(ctx, dir, []string{hash})
 	if err != nil {
 		return "", err
 	}
+	return patchIds[hash], nil
+}
 
-	patchId := strings.Split(string(out), " ")[0]
-	c.Add(hash, patchId)
+// GetOrCreatePatchIds returns the stable patch ids of the commits with the given hashes.
+// The patch ids of all commits missing in the cache are calculated from the output
+// of a single git diff-tree process, instead of starting processes per commit.
+// The patch id is empty for commits without changes, e.g., most merge commits.
+func (c *PatchIdCache) GetOrCreatePatchIds(ctx context.Context, dir string, hashes []string) (map[string]string, error) {
+	patchIds := make(map[string]string, len(hashes))
+	missing := make([]string, 0)
+	for _, h := range hashes {
+		// hashes are returned by the forge, so they can't be trusted
+		if !validSha(h) {
+			continue
+		}
+		if patchId, ok := c.get(h); ok {
+			patchIds[h] = patchId
+		} else {
+			missing = append(missing, h)
+		}
+	}
+	if len(missing) == 0 {
+		return patchIds, nil
+	}
 
-	return patchId, nil
+	created, err := createPatchIds(ctx, dir, missing)
+	if err != nil {
+		return nil, err
+	}
+	// commits without changes are not part of the output of git patch-id,
+	// neither are commits which don't exist in the repository
+	var unchanged []string
+	for _, h := range missing {
+		if patchId, ok := created[h]; ok {
+			patchIds[h] = patchId
+			c.Add(h, patchId)
+		} else {
+			unchanged = append(unchanged, h)
+		}
+	}
+	if len(unchanged) == 0 {
+		return patchIds, nil
+	}
+
+	existing, err := existingCommits(ctx, dir, unchanged)
+	if err != nil {
+		return nil, err
+	}
+	for _, h := range unchanged {
+		if existing[h] {
+			patchIds[h] = ""
+			c.Add(h, "")
+		}
+	}
+	return patchIds, nil
+}
+
+// existingCommits returns which of the hashes are commits of the repository.
+func existingCommits(ctx context.Context, dir string, hashes []string) (map[string]bool, error) {
+	cmd := newGitCmd(ctx, dir, shortTimeout, "cat-file", "--batch-check=%(objectname) %(objecttype)")
+	cmd.stdin = strings.NewReader(strings.Join(hashes, "\n") + "\n")
+	out, err := cmd.output()
+	if err != nil {
+		return nil, err
+	}
+
+	// missing objects are reported as "<hash> missing"
+	res := make(map[string]bool, len(hashes))
+	for _, line := range strings.Split(string(out), "\n") {
+		hash, objectType, _ := strings.Cut(line, " ")
+		if objectType == "commit" {
+			res[hash] = true
+		}
+	}
+	return res, nil
+}
+
+// createPatchIds calculates the patch ids of all commits from the output of a single
+// git diff-tree process. The options of diff-tree match the defaults of git show, so the
+// patch ids are the same as of git show <hash> | git patch-id --stable.
+func createPatchIds(ctx context.Context, dir string, hashes []string) (map[string]string, error) {
+	diffTree := newGitCmd(ctx, dir, longTimeout, "diff-tree", "--stdin", "-p", "--root", "-M", "--pretty=format:commit %H")
+	diffTree.stdin = strings.NewReader(strings.Join(hashes, "\n") + "\n")
+
+	patches, err := diffTree.start()
+	if err != nil {
+		return nil, err
+	}
+
+	res, parseErr := stablePatchIds(patches)
+	// diff-tree blocks if its output isn't read
+	if err := diffTree.wait(parseErr != nil); err != nil && parseErr == nil {
+		return nil, err
+	}
+	if parseErr != nil {
+		return nil, parseErr
+	}
+	return res, nil
+}
+
+// LogStats logs the number of cache hits and misses since the last call and resets them,
+// so the statistics of a shared cache are logged per repository.
+func (c *PatchIdCache) LogStats() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	hitRate := 0.0
+	if c.hits+c.misses > 0 {
+		hitRate = float64(c.hits) / float64(c.hits+c.misses)
+	}
+	slog.Default().Info("Patch id cache statistics", "hits", c.hits, "misses", c.misses, "hit rate", hitRate, "entries", len(c.entries))
+	c.hits, c.misses = 0, 0
+}
+
+// Close compacts the file of the cache. The file is replaced atomically,
+// so concurrent readers never see a partial file.
+// The cache must not be used afterwards.
+func (c *PatchIdCache) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.file == nil {
+		return nil
+	}
+	if err := c.file.Close(); err != nil {
+		return err
+	}
+	c.file = nil
+
+	tmp, err := os.CreateTemp(filepath.Dir(c.path), ".patchids-*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		// fails if the file has been renamed
+		_ = os.Remove(tmp.Name())
+	}()
+
+	w := bufio.NewWriter(tmp)
+	// oldest entries first, so the order of recency is kept on the next load
+	for e := c.lru.Back(); e != nil; e = e.Prev() {
+		entry := e.Value.(*patchIdEntry)
+		if _, err := fmt.Fprintf(w, "%s %s\n", entry.hash, entry.patchId); err != nil {
+			_ = tmp.Close()
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), c.path)
 }
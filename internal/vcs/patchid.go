@@ -0,0 +1,292 @@
+package vcs
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"io"
+)
+
+// stablePatchIds calculates the stable patch ids of all commits in the output of
+// git log -p or git diff-tree -p with a "commit <hash>" header for each commit.
+// The result is the same as of git patch-id --stable. Commits without changes are omitted.
+//
+// The implementation follows builtin/patch-id.c of git: all whitespace is removed from
+// the lines of a patch, line numbers of hunks and index lines are ignored, and the sha1
+// of each file is summed up, so the patch id doesn't depend on the order of the files.
+func stablePatchIds(r io.Reader) (map[string]string, error) {
+	p := patchIdParser{
+		reader: bufio.NewReaderSize(r, 64*1024),
+		hash:   sha1.New(),
+	}
+
+	res := make(map[string]string)
+	commit := ""
+	for !p.eof {
+		next, patchId, patchLen, err := p.next()
+		if err != nil {
+			return nil, err
+		}
+		if patchLen > 0 && commit != "" {
+			res[commit] = patchId
+		}
+		commit = next
+	}
+	return res, nil
+}
+
+type patchIdParser struct {
+	reader *bufio.Reader
+	eof    bool
+	hash   hash.Hash
+	result [sha1.Size]byte
+}
+
+// next reads the patch of one commit and returns its patch id, the number of
+// bytes hashed, and the hash of the next commit, if its header has been found.
+func (p *patchIdParser) next() (string, string, int, error) {
+	p.hash.Reset()
+	p.result = [sha1.Size]byte{}
+
+	patchLen := 0
+	before, after := -1, -1
+	isBinary := false
+	var preOid, postOid []byte
+	next := ""
+
+	for {
+		line, err := p.reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			p.eof = true
+			if len(line) == 0 {
+				break
+			}
+		} else if err != nil {
+			return "", "", 0, err
+		}
+
+		rest := line
+		if r, ok := bytes.CutPrefix(line, []byte("diff-tree ")); ok {
+			rest = r
+		} else if r, ok := bytes.CutPrefix(line, []byte("commit ")); ok {
+			rest = r
+		} else if r, ok := bytes.CutPrefix(line, []byte("From ")); ok {
+			rest = r
+		} else if bytes.HasPrefix(line, []byte("\\ ")) && len(line) > 12 {
+			// e.g., "\ No newline at end of file"
+			if p.eof {
+				break
+			}
+			continue
+		}
+
+		if isHash(rest) {
+			next = string(rest[:2*sha1.Size])
+			break
+		}
+
+		// ignore the commit message
+		if patchLen == 0 && !bytes.HasPrefix(line, []byte("diff ")) {
+			if p.eof {
+				break
+			}
+			continue
+		}
+
+		// parsing the header of a file
+		if before == -1 {
+			if bytes.HasPrefix(line, []byte("GIT binary patch")) || bytes.HasPrefix(line, []byte("Binary files")) {
+				isBinary = true
+				before = 0
+				p.hash.Write(preOid)
+				p.hash.Write(postOid)
+				p.flushHunk()
+				if p.eof {
+					break
+				}
+				continue
+			} else if bytes.HasPrefix(line, []byte("index ")) {
+				preOid, postOid = parseIndexLine(line)
+				if p.eof {
+					break
+				}
+				continue
+			} else if bytes.HasPrefix(line, []byte("--- ")) {
+				before, after = 1, 1
+			} else if !isAlpha(line[0]) {
+				break
+			}
+		}
+
+		if isBinary {
+			if bytes.HasPrefix(line, []byte("diff ")) {
+				isBinary = false
+				before = -1
+			}
+			if p.eof {
+				break
+			}
+			continue
+		}
+
+		// looking for the header of the next hunk
+		if before == 0 && after == 0 {
+			if bytes.HasPrefix(line, []byte("@@ -")) {
+				// the line numbers are ignored
+				scanHunkHeader(line, &before, &after)
+				if p.eof {
+					break
+				}
+				continue
+			}
+
+			// end of the patch
+			if !bytes.HasPrefix(line, []byte("diff ")) {
+				break
+			}
+
+			// header of the next file
+			p.flushHunk()
+			before, after = -1, -1
+		}
+
+		// inside of a hunk
+		if line[0] == '-' || line[0] == ' ' {
+			before--
+		}
+		if line[0] == '+' || line[0] == ' ' {
+			after--
+		}
+
+		line = removeSpace(line)
+		patchLen += len(line)
+		p.hash.Write(line)
+
+		if p.eof {
+			break
+		}
+	}
+
+	p.flushHunk()
+	return next, hex.EncodeToString(p.result[:]), patchLen, nil
+}
+
+// flushHunk adds the sha1 of the current file to the result. The bytes of
+// both are summed up with carry, so the order of the files doesn't matter.
+func (p *patchIdParser) flushHunk() {
+	sum := p.hash.Sum(nil)
+	p.hash.Reset()
+
+	carry := 0
+	for i := range p.result {
+		carry += int(p.result[i]) + int(sum[i])
+		p.result[i] = byte(carry)
+		carry >>= 8
+	}
+}
+
+// parseIndexLine returns the abbreviated object ids of an index line, e.g.,
+// "index 1234567..89abcde 100644". They identify the content of binary files.
+func parseIndexLine(line []byte) ([]byte, []byte) {
+	oids := line[len("index "):]
+	oid1End := bytes.Index(line, []byte(".."))
+	if oid1End == -1 {
+		return nil, nil
+	}
+	oid2 := line[oid1End+2:]
+	oid2End := bytes.IndexByte(oid2, ' ')
+	if oid2End == -1 {
+		// git cuts off the last character, which is the line break
+		oid2End = max(len(oid2)-1, 0)
+	}
+	return truncate(oids[:oid1End-len("index ")]), truncate(oid2[:oid2End])
+}
+
+// truncate limits an object id to the max length of sha256 hashes, like git.
+func truncate(oid []byte) []byte {
+	if len(oid) > 64 {
+		return oid[:64]
+	}
+	return oid
+}
+
+// scanHunkHeader parses the number of lines before and after the change
+// from a hunk header, e.g., "@@ -1,5 +1,6 @@".
+func scanHunkHeader(line []byte, before, after *int) bool {
+	q := line[4:]
+	n := digits(q)
+	if n < len(q) && q[n] == ',' {
+		q = q[n+1:]
+		*before = atoi(q)
+		n = digits(q)
+	} else {
+		*before = 1
+	}
+
+	if n == 0 || n+1 >= len(q) || q[n] != ' ' || q[n+1] != '+' {
+		return false
+	}
+
+	r := q[n+2:]
+	n = digits(r)
+	if n < len(r) && r[n] == ',' {
+		r = r[n+1:]
+		*after = atoi(r)
+		n = digits(r)
+	} else {
+		*after = 1
+	}
+	return n != 0
+}
+
+// digits returns the number of leading digits of b.
+func digits(b []byte) int {
+	for i, c := range b {
+		if c < '0' || c > '9' {
+			return i
+		}
+	}
+	return len(b)
+}
+
+func atoi(b []byte) int {
+	n := 0
+	for _, c := range b[:digits(b)] {
+		n = n*10 + int(c-'0')
+	}
+	return n
+}
+
+// isHash reports whether b starts with a hexadecimal sha1 hash.
+func isHash(b []byte) bool {
+	if len(b) < 2*sha1.Size {
+		return false
+	}
+	for _, c := range b[:2*sha1.Size] {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') && (c < 'A' || c > 'F') {
+			return false
+		}
+	}
+	return true
+}
+
+func isAlpha(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+// removeSpace removes all whitespace in place. Like git, vertical tabs and
+// form feeds are not considered whitespace.
+func removeSpace(line []byte) []byte {
+	res := line[:0]
+	for _, c := range line {
+		switch c {
+		case ' ', '\t', '\n', '\r':
+		default:
+			res = append(res, c)
+		}
+	}
+	return res
+}
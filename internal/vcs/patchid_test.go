@@ -0,0 +1,149 @@
+package vcs
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"project-integrity-calculator/internal/gittest"
+)
+
+// fixtureRepo creates a repository with commits covering the cases git patch-id
+// treats specially, e.g., binary files, renames, mode changes, and merges.
+func fixtureRepo(t *testing.T) *gittest.Repo {
+	t.Helper()
+	r := gittest.New(t)
+
+	r.WriteFile("a.txt", "one\ntwo\nthree\nfour\nfive\nsix\nseven\neight\nnine\nten\n")
+	r.WriteFile("src/b.go", "package b\n\nfunc B() int {\n\treturn 1\n}\n")
+	r.WriteFile("c.txt", "line\n")
+	r.Commit("root commit")
+
+	r.WriteFile("a.txt", "one\ntwo\n3\nfour\nfive\nsix\nseven\neight\n9\nten\neleven\n")
+	r.Commit("change multiple hunks")
+
+	r.WriteFile("src/b.go", "package b\n\nfunc   B() int {\n        return 1\n}\n")
+	r.Commit("change whitespace only")
+
+	r.WriteFile("c.txt", "line\nwithout newline")
+	r.Commit("no newline at end of file")
+
+	r.WriteFile("d.txt", "windows\r\nline endings\r\n")
+	r.Commit("crlf")
+
+	r.WriteFile("img.bin", "\x00\x01\x02binary\x00content")
+	r.Commit("add binary file")
+
+	r.WriteFile("img.bin", "\x00\x01\x02changed binary\x00content")
+	r.WriteFile("a.txt", "zero\none\ntwo\n3\nfour\nfive\nsix\nseven\neight\n9\nten\neleven\n")
+	r.Commit("change binary and text file")
+
+	r.Git("mv", "src/b.go", "src/renamed.go")
+	r.Commit("rename file")
+
+	r.Git("mv", "a.txt", "moved.txt")
+	r.WriteFile("moved.txt", "zero\none\ntwo\n3\nfour\nfive\nsix\nseven\neight\n9\nten\neleven\ntwelve\n")
+	r.Commit("rename and change file")
+
+	if err := os.Chmod(filepath.Join(r.Dir, "c.txt"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	r.Commit("change mode")
+
+	r.Git("rm", "-q", "d.txt")
+	r.Commit("delete file")
+
+	r.Commit("empty commit")
+
+	r.WriteFile("e.txt", "commit 0123456789abcdef0123456789abcdef01234567\nFrom someone\n\\ not a marker\n")
+	r.Commit("lines looking like headers")
+
+	r.Git("checkout", "-q", "-b", "feature")
+	r.WriteFile("feature.txt", "feature\n")
+	r.Commit("feature")
+	r.WriteFile("c.txt", "feature line\n")
+	r.Commit("conflicting feature")
+
+	r.Git("checkout", "-q", "main")
+	r.WriteFile("c.txt", "main line\n")
+	r.Commit("conflicting main")
+	// the merge fails with a conflict, which is resolved differently than on both branches
+	_ = r.TryGit("merge", "-q", "--no-ff", "feature", "-m", "merge")
+	r.WriteFile("c.txt", "resolved line\n")
+	r.Commit("merge feature")
+
+	return r
+}
+
+func TestStablePatchIdsMatchGitPatchId(t *testing.T) {
+	r := fixtureRepo(t)
+	hashes := strings.Fields(r.Git("rev-list", "--all"))
+	patches := r.Run(strings.Join(hashes, "\n")+"\n",
+		"diff-tree", "--stdin", "-p", "--root", "-M", "--pretty=format:commit %H")
+
+	got, err := stablePatchIds(strings.NewReader(patches))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := make(map[string]string)
+	for _, line := range strings.Split(r.Run(patches, "patch-id", "--stable"), "\n") {
+		if patchId, hash, ok := strings.Cut(line, " "); ok {
+			want[hash] = patchId
+		}
+	}
+
+	if len(want) == 0 {
+		t.Fatal("git patch-id didn't return any patch ids")
+	}
+	if len(got) != len(want) {
+		t.Errorf("got %d patch ids, want %d", len(got), len(want))
+	}
+	for hash, patchId := range want {
+		if got[hash] != patchId {
+			t.Errorf("patch id of %s is %q, want %q", hash, got[hash], patchId)
+		}
+	}
+}
+
+func TestGetOrCreatePatchIdsMatchGitShow(t *testing.T) {
+	r := fixtureRepo(t)
+	// git show uses a combined diff for merges, which isn't supported
+	hashes := strings.Fields(r.Git("rev-list", "--no-merges", "--all"))
+
+	cache := NewPatchIdCache(len(hashes))
+	got, err := cache.GetOrCreatePatchIds(context.Background(), r.Dir, hashes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, hash := range hashes {
+		show := r.Git("show", hash)
+		want, _, _ := strings.Cut(r.Run(show, "patch-id", "--stable"), " ")
+		patchId, ok := got[hash]
+		if !ok {
+			t.Errorf("no patch id of %s", hash)
+		} else if patchId != want {
+			t.Errorf("patch id of %s is %q, want %q", hash, patchId, want)
+		}
+	}
+}
+
+func TestGetOrCreatePatchIdsSkipsMissingCommits(t *testing.T) {
+	r := fixtureRepo(t)
+	missing := "0123456789abcdef0123456789abcdef01234567"
+
+	cache := NewPatchIdCache(10)
+	got, err := cache.GetOrCreatePatchIds(context.Background(), r.Dir, []string{missing})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := got[missing]; ok {
+		t.Errorf("got patch id of missing commit")
+	}
+	if _, ok := cache.get(missing); ok {
+		t.Errorf("missing commit has been cached")
+	}
+}
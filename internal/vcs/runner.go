@@ -0,0 +1,247 @@
+package vcs
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+const (
+	// timeout of commands reading little data of the repository
+	shortTimeout = time.Minute
+	// timeout of commands reading the whole history of the repository
+	longTimeout = time.Hour
+	// timeout of commands transferring data from the remote
+	remoteTimeout = 2 * time.Hour
+)
+
+// passedEnv are the only environment variables passed to git, e.g., to find the
+// git binary and to connect through proxies.
+var passedEnv = []string{
+	"PATH", "HOME", "TMPDIR", "SSH_AUTH_SOCK",
+	"HTTP_PROXY", "HTTPS_PROXY", "NO_PROXY", "ALL_PROXY",
+	"http_proxy", "https_proxy", "no_proxy", "all_proxy",
+}
+
+// hardenedConfig is applied to all git commands. Config passed through the environment
+// takes precedence over the config of the repository, so untrusted repositories can't
+// execute code through hooks, the file system monitor, or credential helpers.
+var hardenedConfig = [][2]string{
+	{"core.hooksPath", os.DevNull},
+	{"core.fsmonitor", "false"},
+	{"credential.helper", ""},
+	{"protocol.ext.allow", "never"},
+	// signatures are verified with fixed programs, see Keyring
+	{"gpg.program", "gpg"},
+	{"gpg.openpgp.program", "gpg"},
+	{"gpg.x509.program", "gpgsm"},
+	{"gpg.ssh.program", "ssh-keygen"},
+}
+
+// gitCmd is a git command executed without a shell and with a clean environment.
+// All git commands of the program must be executed through gitCmd.
+type gitCmd struct {
+	parent  context.Context
+	dir     string
+	args    []string
+	stdin   io.Reader
+	timeout time.Duration
+	config  [][2]string
+	// additional environment variables, e.g., of the Keyring
+	env []string
+
+	cmd    *exec.Cmd
+	ctx    context.Context
+	cancel context.CancelFunc
+	stderr bytes.Buffer
+}
+
+// newGitCmd creates a git command in dir, which is stopped after timeout or if ctx is done.
+// Arguments derived from external data must be validated and separated from the options by "--".
+func newGitCmd(ctx context.Context, dir string, timeout time.Duration, args ...string) *gitCmd {
+	return &gitCmd{
+		parent:  ctx,
+		dir:     dir,
+		args:    args,
+		timeout: timeout,
+		config:  hardenedConfig,
+	}
+}
+
+// withAuth sends the header returned by auth to the remote.
+func (g *gitCmd) withAuth(auth Auth) error {
+	if auth == nil {
+		return nil
+	}
+	header, err := auth(g.parent)
+	if err != nil || header == "" {
+		return err
+	}
+	g.config = append(g.config[:len(g.config):len(g.config)], [2]string{"http.extraHeader", "Authorization: " + header})
+	return nil
+}
+
+// withKeyring verifies signatures of commits with the keys of k instead of
+// the keys of the user.
+func (g *gitCmd) withKeyring(k *Keyring) {
+	if k == nil {
+		return
+	}
+	g.config = append(g.config[:len(g.config):len(g.config)], k.config()...)
+	g.env = append(g.env[:len(g.env):len(g.env)], k.env()...)
+}
+
+// cleanEnv returns the variables of passedEnv from the environment of the program.
+func cleanEnv() []string {
+	env := make([]string, 0, len(passedEnv))
+	for _, name := range passedEnv {
+		if value, ok := os.LookupEnv(name); ok {
+			env = append(env, name+"="+value)
+		}
+	}
+	return env
+}
+
+// environ returns the environment of the command. Besides passedEnv, only variables
+// disabling the system and global config and interactive prompts are set.
+// The header of withAuth is passed through environment variables to not expose
+// it in the process list or store it in the config of the repository.
+func (g *gitCmd) environ() []string {
+	env := append(cleanEnv(), g.env...)
+	env = append(env,
+		"GIT_CONFIG_NOSYSTEM=1",
+		"GIT_CONFIG_GLOBAL="+os.DevNull,
+		"GIT_TERMINAL_PROMPT=0",
+		"GIT_NO_REPLACE_OBJECTS=1",
+		fmt.Sprintf("GIT_CONFIG_COUNT=%d", len(g.config)),
+	)
+	for i, c := range g.config {
+		env = append(env,
+			fmt.Sprintf("GIT_CONFIG_KEY_%d=%s", i, c[0]),
+			fmt.Sprintf("GIT_CONFIG_VALUE_%d=%s", i, c[1]),
+		)
+	}
+	return env
+}
+
+func (g *gitCmd) prepare() {
+	g.ctx, g.cancel = context.WithTimeout(g.parent, g.timeout)
+	g.cmd = exec.CommandContext(g.ctx, "git", g.args...)
+	g.cmd.Dir = g.dir
+	g.cmd.Env = g.environ()
+	g.cmd.Stdin = g.stdin
+	g.cmd.Stderr = &g.stderr
+	// git cleans up on interrupts, e.g., removes the directory of an incomplete clone.
+	// It is killed if it doesn't exit in time.
+	g.cmd.Cancel = func() error {
+		return g.cmd.Process.Signal(os.Interrupt)
+	}
+	// child processes, e.g., the remote helpers of git fetch, might keep the pipes open after git has been killed
+	g.cmd.WaitDelay = 10 * time.Second
+}
+
+// output runs the command and returns its standard output.
+func (g *gitCmd) output() ([]byte, error) {
+	g.prepare()
+	defer g.cancel()
+	out, err := g.cmd.Output()
+	return out, g.wrap(err)
+}
+
+// run runs the command and discards its output.
+func (g *gitCmd) run() error {
+	_, err := g.output()
+	return err
+}
+
+// start starts the command and returns a pipe of its standard output.
+// wait must be called after reading the output.
+func (g *gitCmd) start() (io.Reader, error) {
+	g.prepare()
+	out, err := g.cmd.StdoutPipe()
+	if err != nil {
+		g.cancel()
+		return nil, err
+	}
+	if err := g.cmd.Start(); err != nil {
+		g.cancel()
+		return nil, g.wrap(err)
+	}
+	return out, nil
+}
+
+// wait waits for a started command. If kill is set, the command is killed first,
+// e.g., because its output isn't read completely and it would block.
+func (g *gitCmd) wait(kill bool) error {
+	if kill {
+		g.cancel()
+	}
+	defer g.cancel()
+	return g.wrap(g.cmd.Wait())
+}
+
+// wrap adds the command and its standard error to err.
+func (g *gitCmd) wrap(err error) error {
+	if err == nil {
+		return nil
+	}
+	name := "git"
+	if len(g.args) > 0 {
+		name += " " + g.args[0]
+	}
+	if ctxErr := g.parent.Err(); ctxErr != nil {
+		return fmt.Errorf("%s stopped: %w", name, ctxErr)
+	}
+	if errors.Is(g.ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("%s timed out after %s: %w", name, g.timeout, err)
+	}
+	stderr := strings.TrimSpace(g.stderr.String())
+	if stderr == "" {
+		return fmt.Errorf("%s failed: %w", name, err)
+	}
+	return fmt.Errorf("%s failed: %w: %s", name, err, stderr)
+}
+
+// validSha reports whether s is a full sha1 or sha256 object id.
+func validSha(s string) bool {
+	if len(s) != 40 && len(s) != 64 {
+		return false
+	}
+	for _, c := range []byte(s) {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') && (c < 'A' || c > 'F') {
+			return false
+		}
+	}
+	return true
+}
+
+// validRef reports whether s is a full refname, e.g., "refs/pull/1/head",
+// following the rules of git check-ref-format.
+func validRef(s string) bool {
+	if !strings.HasPrefix(s, "refs/") || strings.HasSuffix(s, "/") || strings.HasSuffix(s, ".") ||
+		strings.Contains(s, "..") || strings.Contains(s, "@{") || strings.Contains(s, "//") {
+		return false
+	}
+	for _, c := range []byte(s) {
+		if c < 0x20 || c == 0x7f || strings.IndexByte(" ~^:?*[\\", c) != -1 {
+			return false
+		}
+	}
+	for _, component := range strings.Split(s, "/") {
+		if strings.HasPrefix(component, ".") || strings.HasSuffix(component, ".lock") {
+			return false
+		}
+	}
+	return true
+}
+
+// validBranch reports whether s is a valid short name of a branch, e.g., "main".
+func validBranch(s string) bool {
+	return s != "" && s != "@" && !strings.HasPrefix(s, "-") && validRef("refs/heads/"+s)
+}